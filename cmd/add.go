@@ -3,7 +3,7 @@ package cmd
 import (
 	"cloakroom/lib"
 	"cloakroom/lib/handlers"
-	"cloakroom/lib/utility"
+	"fmt"
 	"github.com/spf13/viper"
 
 	"github.com/spf13/cobra"
@@ -19,30 +19,53 @@ You can specify the plugin's repository and release details. Optionally, use the
 
 Example:
   cloakroom add example/my-plugin --tag v1.2.0 --artifact plugin.jar
-  cloakroom add example/my-plugin --tag v1.3.5 --artifact plugin.jar --fetch`,
+  cloakroom add example/my-plugin --tag v1.3.5 --artifact plugin.jar --fetch
+  cloakroom add example/my-theme --tag v2.0.0 --artifact theme.zip --archive zip --extract "*.jar" --strip-components 1`,
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		wardrobe := viper.GetString(utility.Wardrobe)
 		manifest := &lib.Manifest{}
 		err := viper.Unmarshal(manifest)
 		cobra.CheckErr(err)
+		err = interpolate(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		if name := profile(); name != "" {
+			fmt.Printf("[WARN] Ignoring profile %s: %s changes the plugins shared by every profile.\n", name, cmd.Name())
+		}
+
+		files(cmd, manifest)
 
 		key := args[0]
 		tag, _ := cmd.Flags().GetString("tag")
 		artifact, _ := cmd.Flags().GetString("artifact")
 		fetch, _ := cmd.Flags().GetBool("fetch")
 		force, _ := cmd.Flags().GetBool("force")
+		archive, _ := cmd.Flags().GetString("archive")
+		extract, _ := cmd.Flags().GetStringSlice("extract")
+		strip, _ := cmd.Flags().GetInt("strip-components")
 
 		plugin := lib.Plugin{
-			Tag:      tag,
-			Artifact: artifact,
+			Tag:             tag,
+			Artifact:        artifact,
+			Archive:         archive,
+			Extract:         extract,
+			StripComponents: strip,
+		}
+
+		// Check the manifest as it will be once the plugin is added
+		proposed := *manifest
+		proposed.Plugins = make(map[string]lib.Plugin, len(manifest.Plugins)+1)
+		for existing, entry := range manifest.Plugins {
+			proposed.Plugins[existing] = entry
 		}
+		proposed.Plugins[key] = plugin
+		enforce(cmd, &proposed)
 
-		err = handlers.Add(manifest, plugin, key, wardrobe, fetch, force)
+		err = handlers.Add(cmd.Context(), manifest, plugin, key, wardrobe, fetch, force)
 		cobra.CheckErr(err)
 
-		viper.Set("plugins", manifest.Plugins)
-		err = viper.WriteConfig()
+		err = save(manifest.Plugins)
 		cobra.CheckErr(err)
 	},
 }
@@ -54,4 +77,9 @@ func init() {
 	addCmd.Flags().String("artifact", "", "Artifact name of the plugin (required).")
 	addCmd.Flags().Bool("fetch", false, "Immediately download the plugin after adding it.")
 	addCmd.Flags().Bool("force", false, "Overwrite existing plugin directories.")
+	addCmd.Flags().String("archive", "", "Extract the artifact as an archive of this format (zip, tar, tar.gz or tgz).")
+	addCmd.Flags().StringSlice("extract", nil, "Only extract archive entries matching these patterns (supports * and **).")
+	addCmd.Flags().Int("strip-components", 0, "Strip this many leading path elements from extracted entries.")
+	fileFlags(addCmd)
+	policyFlag(addCmd)
 }
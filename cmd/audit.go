@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Check plugins for known vulnerabilities against a local OSV database.",
+	Long: `The audit command matches every plugin, and the Maven projects embedded in its installed JARs
+(including libraries shaded into jar-with-dependencies builds), against a local OSV or GHSA data dump.
+
+The database is a directory of OSV JSON records or a zip of them, such as the per-ecosystem exports at
+https://osv-vulnerabilities.storage.googleapis.com/Maven/all.zip. Nothing is downloaded, so the audit
+can run in sealed CI runners.
+
+The command fails when a vulnerability at or above --fail-on is found.
+
+Examples:
+  cloakroom audit --db ./osv-export
+  cloakroom audit --db ./Maven-all.zip --fail-on critical`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		db, _ := cmd.Flags().GetString("db")
+		threshold, _ := cmd.Flags().GetString("fail-on")
+
+		err = handlers.Audit(manifest, wardrobe, db, threshold)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().String("db", "", "OSV database: a directory of JSON records or a zip of them (required).")
+	auditCmd.Flags().String("fail-on", "high", "Lowest severity that fails the audit: unknown, low, medium, high, critical or none.")
+	_ = auditCmd.MarkFlagRequired("db")
+}
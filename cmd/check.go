@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Detect conflicting classes and providers across plugins.",
+	Long: `The check command scans every JAR in the wardrobe for duplicate fully-qualified class names
+and duplicate META-INF/services registrations, which Keycloak rejects at build time.
+
+Conflicts are reported with the owner/repo keys of the plugins involved. JARs not in the manifest are included and labelled as unmanaged.
+The check also runs automatically after restore.
+
+Example:
+  cloakroom check`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		err = handlers.Check(manifest, wardrobe)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
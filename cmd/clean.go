@@ -1,10 +1,9 @@
 package cmd
 
 import (
+	"cloakroom/lib"
 	"cloakroom/lib/handlers"
-	"cloakroom/lib/utility"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // cleanCmd represents the clean command
@@ -15,16 +14,25 @@ var cleanCmd = &cobra.Command{
 It is used to prepare for a fresh environment without altering the manifest or lock file.
 
 The wardrobe directory must be defined before running this command.
+Use the --target flag to clean other install targets defined in the manifest instead.
 
 Example:
-  cloakroom clean`,
+  cloakroom clean
+  cloakroom clean --target themes --target providers`,
 	Run: func(cmd *cobra.Command, args []string) {
-		wardrobe := viper.GetString(utility.Wardrobe)
-		err := handlers.Clean(wardrobe)
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		targets, _ := cmd.Flags().GetStringSlice("target")
+		err = handlers.Clean(manifest, wardrobe, targets)
 		cobra.CheckErr(err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(cleanCmd)
+
+	cleanCmd.Flags().StringSlice("target", nil, "Install target to clean (repeatable; defaults to providers).")
 }
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"cloakroom/lib/utility"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show the manifests in use and the manifest they resolve to.",
+	Long: `The config command lists the manifests that were read, from lowest to highest precedence, and every value
+one of them overrides. With --resolved, it prints the effective manifest instead: every manifest and include merged,
+with environment overrides and the selected profile applied.
+
+Manifests are merged in the order they are given with --manifest, each taking precedence over the ones before it,
+and the files a manifest includes come before it. Plugins are merged by key, and the entries of targets, network,
+files, hooks and profiles by name.
+
+Examples:
+  cloakroom config --manifest base.yaml --manifest prod.yaml
+  cloakroom config --resolved --format yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		show, _ := cmd.Flags().GetBool("resolved")
+		format, _ := cmd.Flags().GetString("format")
+
+		if !show {
+			handlers.Sources(resolved)
+			return
+		}
+
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+
+		if format == "" {
+			format = utility.FormatOf(resolved.Primary())
+		}
+		err = handlers.Resolved(manifest, format, os.Stdout)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.Flags().Bool("resolved", false, "Print the effective manifest.")
+	configCmd.Flags().String("format", "", "Format of the effective manifest: "+strings.Join(utility.ManifestFormats, ", ")+" (default: the primary manifest's).")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"cloakroom/lib/handlers"
+	"cloakroom/lib/utility"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Rewrite the manifest in another format.",
+	Long: `The convert command writes the current manifest in another format, next to it by default
+(cloakroom.json becomes cloakroom.yaml), and can delete the original afterwards.
+
+Plugin keys keep their "owner/repo" form in every format: quoted keys in JSON, YAML and TOML,
+plugins "owner/repo" { blocks in HCL and [plugins.owner/repo] sections in INI. The new file is read back and
+compared with the original before the original is deleted. Comments aren't carried over.
+
+Examples:
+  cloakroom convert --to yaml --delete
+  cloakroom convert --to toml --output deploy/cloakroom.toml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("to")
+		output, _ := cmd.Flags().GetString("output")
+		force, _ := cmd.Flags().GetBool("force")
+		remove, _ := cmd.Flags().GetBool("delete")
+
+		err := handlers.Convert(viper.ConfigFileUsed(), format, output, force, remove)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().String("to", "", "Format to convert to: "+strings.Join(utility.ManifestFormats, ", ")+".")
+	convertCmd.Flags().String("output", "", "File to write instead of the manifest's name with the new extension.")
+	convertCmd.Flags().Bool("force", false, "Overwrite the output file if it exists.")
+	convertCmd.Flags().Bool("delete", false, "Delete the original manifest once converted.")
+	_ = convertCmd.MarkFlagRequired("to")
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Generate Dockerfile, shell or make snippets that install the manifest's plugins.",
+	Long: `The export command prints the commands that install every plugin in the manifest without cloakroom,
+for builds that can't run it, such as distroless builders or strict supply-chain policies.
+
+Formats:
+  dockerfile  ADD instructions, for a Dockerfile or Containerfile
+  sh          a POSIX shell script using curl
+  make        a Makefile with one target per plugin
+
+Download URLs are built exactly as 'cloakroom restore' builds them. Every plugin is downloaded once (and checked
+against its manifest hash) to compute the SHA-256 verified by ADD --checksum or sha256sum; the export fails if a
+plugin can't be downloaded. Use --skip-checksum to leave the checksums out. Archive plugins are skipped.
+
+With --output, the file is only replaced once the export has succeeded.
+
+Examples:
+  cloakroom export --format dockerfile --output Dockerfile.plugins
+  cloakroom export --format sh --output install-plugins.sh
+  cloakroom export --format make --providers /opt/keycloak/providers`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+
+		format, _ := cmd.Flags().GetString("format")
+		providers, _ := cmd.Flags().GetString("providers")
+		skipChecksum, _ := cmd.Flags().GetBool("skip-checksum")
+		output, _ := cmd.Flags().GetString("output")
+
+		options := handlers.ExportOptions{
+			Format:       format,
+			Providers:    providers,
+			SkipChecksum: skipChecksum,
+			Source:       viper.ConfigFileUsed(),
+		}
+		if output == "" || output == "-" {
+			err = handlers.Export(cmd.Context(), manifest, options, os.Stdout)
+			cobra.CheckErr(err)
+			return
+		}
+
+		// The snippet is written next to the output and moved into place once complete, so a failed export
+		// leaves any previous file as it was
+		partial := output + ".partial"
+		out, err := os.Create(partial)
+		cobra.CheckErr(err)
+
+		err = handlers.Export(cmd.Context(), manifest, options, out)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(partial, output)
+		}
+		if err != nil {
+			_ = os.Remove(partial)
+		}
+		cobra.CheckErr(err)
+
+		_, _ = fmt.Fprintf(os.Stderr, "[INFO] Exported %s snippet to %s\n", format, output)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().String("format", "dockerfile", "Output format: "+strings.Join(handlers.Formats, ", ")+".")
+	exportCmd.Flags().String("providers", "/opt/keycloak/providers", "Directory of the providers target where the snippet runs.")
+	exportCmd.Flags().Bool("skip-checksum", false, "Don't download each plugin to include its SHA-256.")
+	exportCmd.Flags().String("output", "", "File to write to instead of standard output.")
+}
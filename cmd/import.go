@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// importCmd groups the commands that build a manifest from an existing setup
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import plugins from an existing setup into the manifest.",
+	Long: `The import commands migrate plugins you already install some other way into the manifest.
+
+Examples:
+  cloakroom import dockerfile ./Dockerfile
+  cloakroom import wardrobe /opt/keycloak/providers`,
+}
+
+// importDockerfileCmd represents the import dockerfile command
+var importDockerfileCmd = &cobra.Command{
+	Use:   "dockerfile <path>",
+	Short: "Import the GitHub release downloads in a Dockerfile.",
+	Long: `The import dockerfile command finds every GitHub release asset a Dockerfile downloads,
+whether with ADD or with curl or wget in a RUN instruction, and merges them into the manifest.
+
+ARG and ENV defaults are expanded. URLs that depend on other build arguments are reported and skipped.
+Plugins already in the manifest are kept unless --force is provided.
+
+Examples:
+  cloakroom import dockerfile ./Dockerfile
+  cloakroom import dockerfile ./Dockerfile --hash`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := viper.Unmarshal(manifest)
+		cobra.CheckErr(err)
+		err = interpolate(manifest)
+		cobra.CheckErr(err)
+
+		hash, _ := cmd.Flags().GetBool("hash")
+		force, _ := cmd.Flags().GetBool("force")
+
+		err = handlers.ImportDockerfile(cmd.Context(), manifest, args[0], hash, force)
+		cobra.CheckErr(err)
+
+		err = save(manifest.Plugins)
+		cobra.CheckErr(err)
+	},
+}
+
+// importWardrobeCmd represents the import wardrobe command
+var importWardrobeCmd = &cobra.Command{
+	Use:   "wardrobe [dir]",
+	Short: "Draft a manifest from the JARs in a providers directory.",
+	Long: `The import wardrobe command identifies hand-installed JARs and writes a draft manifest for them.
+
+Each JAR is hashed, and its GitHub repository and version are guessed from MANIFEST.MF and the Maven
+pom.properties and pom.xml bundled inside it. The manifest's host and mirrors (github.com if there is no
+manifest) are then searched for a release asset with the same name and digest.
+
+Matched JARs are written as plugin entries; unidentified ones are listed as comments with what was found,
+so they can be completed by hand. The directory defaults to CLOAKROOM_WARDROBE.
+
+Examples:
+  cloakroom import wardrobe /opt/keycloak/providers
+  cloakroom import wardrobe ./providers --output cloakroom.yaml --offline`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := viper.Unmarshal(manifest)
+		cobra.CheckErr(err)
+		err = interpolate(manifest)
+		cobra.CheckErr(err)
+		if manifest.Host == "" {
+			manifest.Host = "github.com"
+		}
+
+		dir := providers(manifest)
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		if dir == "" {
+			cobra.CheckErr("no directory given and neither CLOAKROOM_WARDROBE nor targets.providers is set")
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		offline, _ := cmd.Flags().GetBool("offline")
+		force, _ := cmd.Flags().GetBool("force")
+
+		err = handlers.ImportWardrobe(cmd.Context(), manifest, dir, output, offline, force)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importDockerfileCmd)
+	importCmd.AddCommand(importWardrobeCmd)
+
+	importDockerfileCmd.Flags().Bool("hash", false, "Download each asset once to record its SHA3-512 hash.")
+	importDockerfileCmd.Flags().Bool("force", false, "Replace plugins that are already in the manifest.")
+
+	importWardrobeCmd.Flags().String("output", "cloakroom.draft.yaml", "File to write the draft manifest to.")
+	importWardrobeCmd.Flags().Bool("offline", false, "Only inspect the JARs; don't search hosts for matching releases.")
+	importWardrobeCmd.Flags().Bool("force", false, "Overwrite the output file if it exists.")
+}
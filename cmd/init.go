@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"cloakroom/lib/utility"
 	"fmt"
 	"os"
 
@@ -16,16 +17,29 @@ var initCmd = &cobra.Command{
 
 If a manifest file already exists, it will not overwrite it unless the --force flag is provided.
 
+New manifests reference the manifest JSON Schema (see 'cloakroom schema') for editor autocomplete and validation:
+through "$schema" in JSON, a yaml-language-server modeline in YAML, or a #:schema directive in TOML.
+
 Example:
   cloakroom init
+  cloakroom init --format yaml
   cloakroom init --force`,
 	Run: func(cmd *cobra.Command, args []string) {
 		force, _ := cmd.Flags().GetBool("force")
+		format, _ := cmd.Flags().GetString("format")
+
+		if format != "json" && format != "yaml" && format != "toml" {
+			fmt.Printf("[ERROR] Unsupported format %q (expected json, yaml or toml)\n", format)
+			return
+		}
 
 		defaultConfig := map[string]interface{}{
 			"version": "1.0",
 			"host":    "github.com",
 		}
+		if format == "json" {
+			defaultConfig["$schema"] = utility.SchemaURL
+		}
 
 		if _, err := os.Stat(viper.ConfigFileUsed()); err == nil {
 			if !force {
@@ -43,11 +57,23 @@ Example:
 			viper.Set(key, value)
 		}
 
-		if err := viper.WriteConfigAs("./cloakroom.json"); err != nil {
+		path := "./" + filename(format)
+		if err := viper.WriteConfigAs(path); err != nil {
 			fmt.Printf("[ERROR] Failed to write manifest: %v\n", err)
 			return
 		}
 
+		if directive := utility.SchemaDirectives[format]; directive != "" {
+			content, err := os.ReadFile(path)
+			if err == nil {
+				err = os.WriteFile(path, append([]byte(directive+utility.SchemaURL+"\n"), content...), 0644)
+			}
+			if err != nil {
+				fmt.Printf("[ERROR] Failed to reference the schema: %v\n", err)
+				return
+			}
+		}
+
 		fmt.Println("[INFO] Configuration file initialized successfully.")
 	},
 }
@@ -56,4 +82,5 @@ func init() {
 	rootCmd.AddCommand(initCmd)
 
 	initCmd.Flags().Bool("force", false, "Overwrite existing manifest file if it exists.")
+	initCmd.Flags().String("format", "json", "Manifest format: json, yaml or toml.")
 }
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// inspectCmd represents the inspect command
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <owner/repo>",
+	Short: "Show the SPI services an installed plugin provides.",
+	Long: `The inspect command opens a plugin's JAR in the wardrobe and shows what it provides.
+
+It reads the title and version from META-INF/MANIFEST.MF and lists every SPI factory interface
+registered in META-INF/services, e.g. org.keycloak.authentication.AuthenticatorFactory, with its implementations.
+
+Example:
+  cloakroom inspect aerogear/keycloak-metrics-spi`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		err = handlers.Inspect(manifest, args[0], wardrobe)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"cloakroom/lib/utility"
+	"github.com/spf13/cobra"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Validate the manifest and check it against the policy file.",
+	Long: `The lint command validates the manifest, as 'cloakroom validate' does, then checks it against a policy file
+and reports every rule it breaks, with the file and line of the offending value.
+
+The policy is read from --policy, the CLOAKROOM_POLICY environment variable, or a cloakroom.policy.yaml
+(or .yml, .json, .toml) file in the current directory. restore and add enforce the same policy before doing any work.
+
+Examples:
+  cloakroom lint
+  cloakroom lint --policy /etc/cloakroom/policy.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := handlers.Validate(resolved)
+		cobra.CheckErr(err)
+
+		manifest := &lib.Manifest{}
+		err = unmarshal(manifest)
+		cobra.CheckErr(err)
+
+		explicit, _ := cmd.Flags().GetString("policy")
+		err = handlers.Lint(manifest, utility.FindPolicy(explicit), resolved)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+	policyFlag(lintCmd)
+}
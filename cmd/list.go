@@ -4,7 +4,6 @@ import (
 	"cloakroom/lib"
 	"cloakroom/lib/handlers"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 // listCmd represents the list command
@@ -13,17 +12,24 @@ var listCmd = &cobra.Command{
 	Short: "List all plugins currently defined in the manifest.",
 	Long: `The list command outputs all the plugin definitions present in your Cloakroom manifest.
 
-It prints essential information such as the release tag, artifact name, and an optional hash for verification. If no plugins are found, it displays a simple message.`,
+It prints essential information such as the release tag, artifact name, and an optional hash for verification. If no plugins are found, it displays a simple message.
+
+Use the --details flag to also show the title, version and SPI services of each installed JAR.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		manifest := &lib.Manifest{}
-		err := viper.Unmarshal(manifest)
+		err := unmarshal(manifest)
 		cobra.CheckErr(err)
 
-		err = handlers.List(manifest)
+		wardrobe := providers(manifest)
+		details, _ := cmd.Flags().GetBool("details")
+
+		err = handlers.List(manifest, wardrobe, details)
 		cobra.CheckErr(err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().Bool("details", false, "Show the SPI services provided by each installed plugin.")
 }
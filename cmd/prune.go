@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd represents the prune command
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove files the manifest does not manage.",
+	Long: `The prune command removes every file and directory in an install target that does not belong
+to a plugin in the manifest, such as JARs left behind after a plugin was removed or upgraded.
+
+Only the providers target is pruned unless --target is given. Use --dry-run to see what would be removed.
+
+Examples:
+  cloakroom prune --dry-run
+  cloakroom prune --target providers --target themes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		targets, _ := cmd.Flags().GetStringSlice("target")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		err = handlers.Prune(manifest, wardrobe, targets, dryRun)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pruneCmd)
+
+	pruneCmd.Flags().StringSlice("target", nil, "Install target to prune (repeatable; defaults to providers).")
+	pruneCmd.Flags().Bool("dry-run", false, "Only report what would be removed.")
+}
@@ -3,7 +3,7 @@ package cmd
 import (
 	"cloakroom/lib"
 	"cloakroom/lib/handlers"
-	"cloakroom/lib/utility"
+	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -21,19 +21,29 @@ Examples:
   cloakroom remove plugin.jar --purge`,
 	Args: cobra.ExactArgs(1), // Requires exactly one argument: artifact name
 	Run: func(cmd *cobra.Command, args []string) {
-		wardrobe := viper.GetString(utility.Wardrobe)
 		manifest := &lib.Manifest{}
 		err := viper.Unmarshal(manifest)
 		cobra.CheckErr(err)
+		err = interpolate(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		if name := profile(); name != "" {
+			fmt.Printf("[WARN] Ignoring profile %s: %s changes the plugins shared by every profile.\n", name, cmd.Name())
+		}
 
 		key := args[0]
 		purge, _ := cmd.Flags().GetBool("purge")
 
+		plugin := manifest.Plugins[key]
 		err = handlers.Remove(manifest, key, wardrobe, purge)
 		cobra.CheckErr(err)
 
-		viper.Set("plugins", manifest.Plugins)
-		err = viper.WriteConfig()
+		// The manifest is saved before the hooks run, so a failing hook doesn't leave it listing purged files
+		err = save(manifest.Plugins)
+		cobra.CheckErr(err)
+
+		err = handlers.PostRemove(cmd.Context(), manifest, key, plugin, wardrobe, purge)
 		cobra.CheckErr(err)
 	},
 }
@@ -20,8 +20,22 @@ By default, it will:
 - Skip installation of plugins that already exist in the target directory.
 
 Flags:
-- Use the --clean (-c) flag to delete existing plugin directories before restoring, ensuring a fresh environment.
+- Use the --clean (-c) flag to empty the wardrobe and every other install target before restoring, ensuring a fresh environment.
 - Use the --force (-f) flag to overwrite plugin directories even if they already exist.
+- Plugins with a keycloak constraint are checked against the target Keycloak version first.
+  The target is taken from --keycloak, the manifest's keycloak version, or the distribution in KC_HOME.
+  Use the --ignore-compatibility flag to warn about mismatches instead of refusing to restore.
+- Use the --skip-check flag to skip checking the wardrobe for conflicting plugins afterwards.
+- Use the --kc-build flag to run kc.sh build afterwards, but only if the installed files changed.
+  kc.sh is found in KC_HOME/bin, or in the bin directory next to the wardrobe.
+
+The manifest is checked against the policy file, if any, before anything else (see 'cloakroom lint').
+
+Installed files get the mode and ownership from the manifest's files section, or from the
+--file-mode, --dir-mode, --owner and --group flags. They are applied before each file is moved into place.
+
+Hooks defined in the manifest run at each stage: pre-restore before anything is downloaded,
+post-install for each plugin that was downloaded, and post-restore once everything is in place.
 
 Examples:
   # Standard restore
@@ -35,16 +49,37 @@ Examples:
 
   # Clean and force restore
   cloakroom restore --clean --force
+
+  # Restore and rebuild Keycloak if any provider changed
+  cloakroom restore --kc-build
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		wardrobe := viper.GetString(utility.Wardrobe)
 		manifest := &lib.Manifest{}
-		err := viper.Unmarshal(manifest)
+		err := unmarshal(manifest)
 		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		files(cmd, manifest)
+		enforce(cmd, manifest)
 
 		clean, _ := cmd.Flags().GetBool("clean")
 		force, _ := cmd.Flags().GetBool("force")
-		err = handlers.Restore(manifest, wardrobe, clean, force)
+		skipCheck, _ := cmd.Flags().GetBool("skip-check")
+		build, _ := cmd.Flags().GetBool("kc-build")
+		keycloak, _ := cmd.Flags().GetString("keycloak")
+		lenient, _ := cmd.Flags().GetBool("ignore-compatibility")
+		home := viper.GetString(utility.KeycloakHome)
+
+		err = handlers.Compatibility(manifest, keycloak, home, wardrobe, lenient)
+		cobra.CheckErr(err)
+
+		err = handlers.Restore(cmd.Context(), manifest, wardrobe, handlers.RestoreOptions{
+			Clean:     clean,
+			Force:     force,
+			SkipCheck: skipCheck,
+			Build:     build,
+			Home:      home,
+		})
 		cobra.CheckErr(err)
 	},
 }
@@ -52,6 +87,12 @@ Examples:
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 
-	restoreCmd.Flags().Bool("clean", false, "Remove all plugins before restoring.")
+	restoreCmd.Flags().Bool("clean", false, "Empty every install target before restoring.")
 	restoreCmd.Flags().Bool("force", false, "Overwrite existing plugin directories.")
+	restoreCmd.Flags().String("keycloak", "", "Target Keycloak version, overriding the manifest and KC_HOME.")
+	restoreCmd.Flags().Bool("ignore-compatibility", false, "Warn about plugins incompatible with the target Keycloak instead of failing.")
+	restoreCmd.Flags().Bool("skip-check", false, "Do not check for conflicting plugins after restoring.")
+	restoreCmd.Flags().Bool("kc-build", false, "Run kc.sh build if the set of providers changed.")
+	fileFlags(restoreCmd)
+	policyFlag(restoreCmd)
 }
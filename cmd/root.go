@@ -1,17 +1,32 @@
 package cmd
 
 import (
+	"bytes"
 	"cloakroom/lib"
+	"cloakroom/lib/handlers"
 	"cloakroom/lib/utility"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"io"
 	"os"
+	"os/signal"
+	"reflect"
+	"sort"
 	"strings"
+	"syscall"
 )
 
-var manifest string
+var manifests []string
+
+// selected is the profile named with --profile.
+var selected string
+
+// resolved is the manifest merged from every file read, or nil when there is none.
+var resolved *utility.Resolved
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -34,24 +49,33 @@ Examples:
   # Clean plugin directory
   cloakroom clean
 `,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		validate(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// The command context is cancelled on SIGINT or SIGTERM so that in-flight work can stop cleanly.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := rootCmd.ExecuteContext(ctx)
 	if err != nil {
+		stop()
 		os.Exit(1)
 	}
 }
 
 func init() {
 	cobra.OnInitialize(configure)
-	rootCmd.PersistentFlags().StringVar(
-		&manifest,
+	rootCmd.PersistentFlags().StringArrayVar(
+		&manifests,
 		"manifest",
-		"",
+		nil,
 		`path to a manifest file (supported formats: .hcl, .ini, .json, .toml, .yaml).
+Repeat it to merge several manifests, each taking precedence over the ones before it.
 If unspecified, cloakroom will look for the following files in the current directory:
   - cloakroom.hcl
   - cloakroom.ini
@@ -59,47 +83,42 @@ If unspecified, cloakroom will look for the following files in the current direc
   - cloakroom.toml
   - cloakroom.yaml
 
-Only one manifest is supported at a time—an error occurs if multiple valid files are found.`,
+When several of them are found they are merged, as long as they don't define the same value differently.`,
 	)
+	rootCmd.PersistentFlags().StringVar(&selected, "profile", "", "Manifest profile to apply (default: CLOAKROOM_PROFILE).")
 }
 
-// configure reads in a manifest file and ENV variables.
+// configure reads in the manifests and ENV variables.
 func configure() {
-	if manifest != "" {
-		viper.SetConfigFile(manifest)
-	} else {
-		options := detect()
-		switch len(options) {
-		case 0:
-			break
-		case 1:
-			viper.AddConfigPath(".")
-			viper.SetConfigType(options[0])
-			viper.SetConfigName(utility.Cloakroom)
-			viper.SetConfigFile(filename(options[0]))
-			break
-		default:
-			message := "[ERROR] Found multiple manifests:\n%s\n\nCloakroom does not support multiple manifests at once."
-			_, _ = fmt.Printf(message, strings.Join(utility.Map(options, filename), "\n- "))
-		}
+	files, strict := manifests, false
+	if len(files) == 0 {
+		files, strict = utility.Map(detect(), filename), true
+	}
+	if len(files) > 0 {
+		viper.SetConfigFile(files[len(files)-1])
 	}
 
 	viper.SetEnvPrefix(utility.Cloakroom)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.AutomaticEnv()
+	for _, key := range utility.Environment {
+		_ = viper.BindEnv(key)
+	}
+	_ = viper.BindEnv(utility.KeycloakHome, "KC_HOME")
 
 	viper.SetDefault("plugins", make(map[string]lib.Plugin))
 
 	err := viper.ReadInConfig()
 	if err == nil {
-		_, _ = fmt.Fprintf(os.Stdout, "[INFO] Using manifest: %s\n", viper.ConfigFileUsed())
+		resolve(files, strict)
 		return
 	}
 
 	var configFileNotFoundError viper.ConfigFileNotFoundError
 	if errors.As(err, &configFileNotFoundError) {
 		cmd, _, _ := rootCmd.Find(os.Args[1:])
-		if cmd != nil && cmd.Name() == "init" {
-			_, _ = fmt.Println("[INFO] No manifest found. This is expected for 'init' command.")
+		if cmd != nil && (cmd.Name() == "init" || cmd.Name() == "serve" || cmd.Name() == "wardrobe" || cmd.Name() == "schema") {
+			_, _ = fmt.Fprintf(notices(), "[INFO] No manifest found. This is expected for '%s' command.\n", cmd.Name())
 			return
 		}
 
@@ -110,12 +129,113 @@ func configure() {
 	cobra.CheckErr(err)
 }
 
+// resolve merges the manifests and everything they include, and makes the result the configuration viper reads.
+// This also puts INI and HCL manifests in the shape cloakroom expects, see utility.Settings.
+func resolve(files []string, strict bool) {
+	var err error
+	resolved, err = utility.Resolve(files, strict)
+	cobra.CheckErr(err)
+	_, _ = fmt.Fprintf(notices(), "[INFO] Using manifest: %s\n", strings.Join(resolved.Files, ", "))
+
+	content, err := json.Marshal(resolved.Settings)
+	cobra.CheckErr(err)
+	viper.SetConfigType("json")
+	cobra.CheckErr(viper.ReadConfig(bytes.NewReader(content)))
+}
+
+// profile returns the name of the profile to apply: --profile, then CLOAKROOM_PROFILE. It returns "" if there is none.
+func profile() string {
+	if selected != "" {
+		return selected
+	}
+	return os.Getenv("CLOAKROOM_PROFILE")
+}
+
+// unmarshal decodes the manifest with the selected profile applied, then interpolated.
+// Commands that write the manifest back decode it with viper.Unmarshal and interpolate instead, so profiles
+// never leak into it.
+func unmarshal(manifest *lib.Manifest) error {
+	if err := viper.Unmarshal(manifest); err != nil {
+		return err
+	}
+
+	if name := profile(); name != "" {
+		_, _ = fmt.Fprintf(notices(), "[INFO] Using profile: %s\n", name)
+		if err := utility.Apply(manifest, name); err != nil {
+			return err
+		}
+	}
+	return interpolate(manifest)
+}
+
+// interpolate expands the environment variables referenced by the manifest's values, see utility.Interpolate.
+func interpolate(manifest *lib.Manifest) error {
+	problems := utility.Interpolate(manifest)
+	if len(problems) == 0 {
+		return nil
+	}
+
+	utility.Sort(problems)
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, fmt.Sprintf("%s: %s", problem.Path, problem.Message))
+	}
+	return fmt.Errorf("can't interpolate the manifest:\n- %s", strings.Join(messages, "\n- "))
+}
+
+// providers returns the wardrobe, the directory of the providers target: CLOAKROOM_WARDROBE if it is set,
+// then the manifest's targets.providers, then its wardrobe setting. It returns "" if there is none.
+func providers(manifest *lib.Manifest) string {
+	if dir := os.Getenv("CLOAKROOM_WARDROBE"); dir != "" {
+		return dir
+	}
+	if dir := manifest.Targets[utility.DefaultTarget]; dir != "" {
+		return dir
+	}
+	return viper.GetString(utility.Wardrobe)
+}
+
+// piped lists the commands whose standard output is data, such as a snippet to redirect into a file.
+// Their informational messages go to standard error instead.
+var piped = map[string]bool{"export": true, "sbom": true, "schema": true, "config": true}
+
+// notices returns where informational messages about the manifest go: standard output, or standard error
+// for piped commands.
+func notices() io.Writer {
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil && piped[cmd.Name()] {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// unvalidated lists the commands that run without validating the manifest first: those that don't need one,
+// and those that report validation problems themselves.
+var unvalidated = map[string]bool{
+	"init": true, "serve": true, "wardrobe": true, "schema": true, "config": true, "validate": true, "lint": true,
+	"help": true, "completion": true, cobra.ShellCompRequestCmd: true, cobra.ShellCompNoDescRequestCmd: true,
+}
+
+// validate checks the manifest strictly before a command runs, exiting on any problem.
+func validate(cmd *cobra.Command) {
+	for parent := cmd; parent != nil; parent = parent.Parent() {
+		if unvalidated[parent.Name()] {
+			return
+		}
+	}
+
+	if resolved == nil {
+		return
+	}
+
+	err := handlers.Validate(resolved)
+	cobra.CheckErr(err)
+}
+
 // detect looks for any valid manifest files
 func detect() []string {
-	formats := []string{"hcl", "ini", "json", "toml", "yaml"}
-	detected := make([]string, 0, len(formats))
+	detected := make([]string, 0, len(utility.ManifestFormats))
 
-	for _, format := range formats {
+	for _, format := range utility.ManifestFormats {
 		_, err := os.Stat(filename(format))
 		if err == nil {
 			detected = append(detected, format)
@@ -129,3 +249,144 @@ func detect() []string {
 func filename(format string) string {
 	return fmt.Sprintf("%s.%s", utility.Cloakroom, format)
 }
+
+// save writes changes to the plugins back to the manifests, comparing them with the plugins the manifests
+// resolved to, interpolated as the command read them: new and changed plugins are written to the primary manifest,
+// and removed plugins are deleted from every manifest that defines them. Only the text of those plugins changes,
+// see utility.Patch, so defaults, environment variables and interpolated values are never written into the files.
+func save(plugins map[string]lib.Plugin) error {
+	before := &lib.Manifest{}
+	if err := utility.Decode(resolved.Settings, before); err != nil {
+		return err
+	}
+	utility.Interpolate(before)
+
+	// edits maps each file to the plugins to write into it, nil for those to remove
+	edits := map[string]map[string]map[string]any{}
+	edit := func(file string, key string, plugin map[string]any) {
+		if edits[file] == nil {
+			edits[file] = map[string]map[string]any{}
+		}
+		edits[file][key] = plugin
+	}
+
+	for key, plugin := range plugins {
+		if previous, found := before.Plugins[key]; found && reflect.DeepEqual(utility.Encode(previous), utility.Encode(plugin)) {
+			continue
+		}
+		encoded, _ := utility.Encode(plugin).(map[string]any)
+		if encoded == nil {
+			encoded = map[string]any{}
+		}
+		edit(resolved.Primary(), key, encoded)
+	}
+
+	for key := range before.Plugins {
+		if _, kept := plugins[key]; kept {
+			continue
+		}
+		for _, file := range resolved.Files {
+			settings, err := utility.Settings(file)
+			if err != nil {
+				return err
+			}
+			if defined, _ := settings["plugins"].(map[string]any); defined != nil && defined[key] != nil {
+				edit(file, key, nil)
+			}
+		}
+	}
+
+	for file, changes := range edits {
+		if err := write(file, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write applies changes to the plugins of a manifest file. If the file can't be edited in place, it is rewritten
+// as a whole, which loses its comments and formatting.
+func write(file string, changes map[string]map[string]any) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	patched := content
+	for _, key := range keys {
+		if patched, err = utility.Patch(patched, utility.FormatOf(file), key, changes[key]); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		fmt.Printf("[WARN] Can't edit %s in place (%v); rewriting it without its comments.\n", file, err)
+		settings, err := utility.Settings(file)
+		if err != nil {
+			return err
+		}
+		defined, _ := settings["plugins"].(map[string]any)
+		if defined == nil {
+			defined = map[string]any{}
+			settings["plugins"] = defined
+		}
+		for key, plugin := range changes {
+			if plugin == nil {
+				delete(defined, key)
+			} else {
+				defined[key] = plugin
+			}
+		}
+		if patched, err = utility.Marshal(settings, utility.FormatOf(file)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+	}
+	return os.WriteFile(file, patched, 0644)
+}
+
+// fileFlags registers the flags that override the manifest's file mode and ownership settings.
+func fileFlags(cmd *cobra.Command) {
+	cmd.Flags().String("file-mode", "", "Mode for installed files, e.g. 0644 (overrides files.mode).")
+	cmd.Flags().String("dir-mode", "", "Mode for created directories, e.g. 0755 (overrides files.dir-mode).")
+	cmd.Flags().String("owner", "", "User name or UID owning installed files, optionally user:group (overrides files.owner).")
+	cmd.Flags().String("group", "", "Group name or GID owning installed files (overrides files.group).")
+}
+
+// files applies any file mode and ownership flags given on the command line to the manifest.
+func files(cmd *cobra.Command, manifest *lib.Manifest) {
+	overrides := map[string]*string{
+		"file-mode": &manifest.Files.Mode,
+		"dir-mode":  &manifest.Files.DirMode,
+		"owner":     &manifest.Files.Owner,
+		"group":     &manifest.Files.Group,
+	}
+
+	for name, field := range overrides {
+		if cmd.Flags().Changed(name) {
+			*field, _ = cmd.Flags().GetString(name)
+		}
+	}
+}
+
+// policyFlag registers the flag that selects the policy file to enforce.
+func policyFlag(cmd *cobra.Command) {
+	cmd.Flags().String("policy", "", "Policy file to enforce (default: CLOAKROOM_POLICY or ./cloakroom.policy.yaml).")
+}
+
+// enforce checks the manifest against the policy, if there is one, exiting on any violation.
+func enforce(cmd *cobra.Command, manifest *lib.Manifest) {
+	explicit, _ := cmd.Flags().GetString("policy")
+	policy := utility.FindPolicy(explicit)
+	if policy == "" {
+		return
+	}
+
+	err := handlers.Lint(manifest, policy, resolved)
+	cobra.CheckErr(err)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"fmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sbomCmd represents the sbom command
+var sbomCmd = &cobra.Command{
+	Use:   "sbom",
+	Short: "Generate a CycloneDX or SPDX SBOM for the plugins in the manifest.",
+	Long: `The sbom command writes a software bill of materials for the plugins in the manifest.
+
+Each plugin is a component with its purl (pkg:github/owner/repo@tag), download URL, hashes and any license
+declared inside its JAR. Libraries shaded into a plugin are listed under it from their embedded pom.properties,
+with pkg:maven purls. Plugins that are not installed are described from the manifest alone.
+
+Examples:
+  cloakroom sbom --format cyclonedx-json --output sbom.cdx.json
+  cloakroom sbom --format spdx-json > sbom.spdx.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		out := os.Stdout
+		if output != "" && output != "-" {
+			out, err = os.Create(output)
+			cobra.CheckErr(err)
+			defer func(out *os.File) {
+				_ = out.Close()
+			}(out)
+		}
+
+		name := strings.TrimSuffix(filepath.Base(viper.ConfigFileUsed()), filepath.Ext(viper.ConfigFileUsed()))
+		err = handlers.Sbom(manifest, wardrobe, format, name, out)
+		cobra.CheckErr(err)
+
+		if out != os.Stdout {
+			_, _ = fmt.Fprintf(os.Stderr, "[INFO] Wrote %s SBOM to %s\n", format, output)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sbomCmd)
+
+	sbomCmd.Flags().String("format", "cyclonedx-json", "SBOM format: "+strings.Join(handlers.SbomFormats, ", ")+".")
+	sbomCmd.Flags().String("output", "", "File to write to instead of standard output.")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"cloakroom/lib/handlers"
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// schemaCmd represents the schema command
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the manifest.",
+	Long: `The schema command prints a JSON Schema describing every manifest field, for autocomplete and inline
+validation in editors such as VS Code and IntelliJ. It doesn't need a manifest.
+
+Manifests created by 'cloakroom init' already reference the published schema: through "$schema" in JSON,
+a yaml-language-server modeline in YAML, or a #:schema directive in TOML.
+
+Examples:
+  cloakroom schema
+  cloakroom schema --output cloakroom.schema.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+
+		out := os.Stdout
+		if output != "" && output != "-" {
+			file, err := os.Create(output)
+			cobra.CheckErr(err)
+			defer func(file *os.File) {
+				_ = file.Close()
+			}(file)
+			out = file
+		}
+
+		err := handlers.Schema(out)
+		cobra.CheckErr(err)
+
+		if out != os.Stdout {
+			_, _ = fmt.Fprintf(os.Stderr, "[INFO] Wrote manifest schema to %s\n", output)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+
+	schemaCmd.Flags().String("output", "", "File to write to instead of standard output.")
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve mirrored plugins over HTTP.",
+	Long: `The serve command exposes a mirror directory over HTTP using the same
+/{owner}/{repo}/releases/download/{tag}/{artifact} layout that restore requests,
+so other machines can use it as their host or as one of their mirrors.
+
+A JSON index of the available releases is served at /index.json.
+
+Use the --sync flag to populate the mirror from the manifest before serving.
+
+Examples:
+  cloakroom serve --addr :8080 --root ./mirror
+  cloakroom serve --root ./mirror --sync`,
+	Run: func(cmd *cobra.Command, args []string) {
+		addr, _ := cmd.Flags().GetString("addr")
+		root, _ := cmd.Flags().GetString("root")
+		sync, _ := cmd.Flags().GetBool("sync")
+
+		if sync {
+			manifest := &lib.Manifest{}
+			err := unmarshal(manifest)
+			cobra.CheckErr(err)
+
+			err = handlers.Sync(cmd.Context(), manifest, root, false)
+			cobra.CheckErr(err)
+		}
+
+		err := handlers.Serve(cmd.Context(), addr, root)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().String("addr", ":8080", "Address to listen on.")
+	serveCmd.Flags().String("root", "./mirror", "Mirror directory to serve.")
+	serveCmd.Flags().Bool("sync", false, "Populate the mirror from the manifest before serving.")
+}
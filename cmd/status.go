@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which plugins are installed in each target.",
+	Long: `The status command compares each install target with the manifest.
+
+Every plugin is reported as installed, missing, or modified (when its hash no longer matches),
+and files in the target that the manifest does not manage are reported as unmanaged.
+
+Examples:
+  cloakroom status
+  cloakroom status --target themes`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+		wardrobe := providers(manifest)
+
+		targets, _ := cmd.Flags().GetStringSlice("target")
+		err = handlers.Status(manifest, wardrobe, targets)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringSlice("target", nil, "Install target to show (repeatable; defaults to all).")
+}
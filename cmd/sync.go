@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/handlers"
+	"github.com/spf13/cobra"
+)
+
+// syncCmd represents the sync command
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Populate a local mirror from the manifest.",
+	Long: `The sync command downloads every plugin in the manifest into a mirror directory,
+laid out as {owner}/{repo}/releases/download/{tag}/{artifact} so it can be served with 'cloakroom serve'.
+
+Releases already present in the mirror are skipped unless --force is provided.
+
+Examples:
+  cloakroom sync --root ./mirror
+  cloakroom sync --root ./mirror --force`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest := &lib.Manifest{}
+		err := unmarshal(manifest)
+		cobra.CheckErr(err)
+
+		root, _ := cmd.Flags().GetString("root")
+		force, _ := cmd.Flags().GetBool("force")
+
+		err = handlers.Sync(cmd.Context(), manifest, root, force)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().String("root", "./mirror", "Mirror directory to populate.")
+	syncCmd.Flags().Bool("force", false, "Overwrite releases already in the mirror.")
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"cloakroom/lib/handlers"
+	"fmt"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the manifest for missing, unknown and invalid fields.",
+	Long: `The validate command checks the manifest strictly and reports every problem with its file and line:
+
+  - version must be "1.0" and host must be set;
+  - plugin keys must be owner/repo, and every plugin needs a tag and an artifact;
+  - artifacts must be plain file names, and no two plugins may install the same artifact into one target;
+  - hashes, archive settings, targets, keycloak constraints and file modes must be well-formed;
+  - fields cloakroom doesn't know about, often typos, are rejected;
+  - required variables referenced with ${VAR:?message} must be set.
+
+Values are checked once their variable references are expanded.
+
+Every other command validates the manifest before doing any work. lint validates it too, before checking the policy.
+
+Examples:
+  cloakroom validate
+  cloakroom validate --manifest staging/cloakroom.yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := handlers.Validate(resolved)
+		cobra.CheckErr(err)
+
+		fmt.Printf("[OK] Manifest %s is valid\n", strings.Join(resolved.Files, ", "))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
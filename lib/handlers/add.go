@@ -8,8 +8,9 @@ import (
 	"github.com/vbauerster/mpb/v8"
 )
 
-// Add adds a plugin to the manifest and optionally downloads it if --fetch is true.
-func Add(manifest *lib.Manifest, plugin lib.Plugin, key string, wardrobe string, fetch bool, force bool) error {
+// Add adds a plugin to the manifest and optionally downloads it if --fetch is true,
+// running its post-install hooks once downloaded.
+func Add(ctx context.Context, manifest *lib.Manifest, plugin lib.Plugin, key string, wardrobe string, fetch bool, force bool) error {
 	if _, exists := manifest.Plugins[key]; exists && !force {
 		return fmt.Errorf("plugin %s already exists in the manifest (use --force to overwrite)", plugin.Artifact)
 	}
@@ -19,10 +20,33 @@ func Add(manifest *lib.Manifest, plugin lib.Plugin, key string, wardrobe string,
 		plugin.Artifact, plugin.Tag, plugin.Artifact)
 
 	if fetch {
-		ctx := context.Background()
-		progress := mpb.New()
+		sources, err := utility.Sources(manifest, key, plugin)
+		if err != nil {
+			return err
+		}
 
-		return utility.Restore(ctx, manifest.Host, wardrobe, key, plugin, force, progress)
+		progress := mpb.NewWithContext(ctx)
+		transfer, err := utility.NewTransfer(manifest, progress)
+		if err != nil {
+			return fmt.Errorf("failed to configure downloads: %w", err)
+		}
+
+		dir, err := utility.Directory(manifest, wardrobe, plugin)
+		if err != nil {
+			return err
+		}
+
+		downloaded, err := utility.Install(ctx, transfer, manifest, sources, dir, key, plugin, force)
+		progress.Wait()
+
+		if ctx.Err() != nil {
+			fmt.Printf("[WARN] Interrupted: %s was not downloaded.\n", key)
+			return fmt.Errorf("fetch interrupted: %w", ctx.Err())
+		}
+		if err != nil || !downloaded {
+			return err
+		}
+		return install(ctx, manifest, wardrobe, key, plugin)
 	}
 
 	return nil
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// finding is a vulnerable package found in a plugin.
+type finding struct {
+	plugin   string
+	coords   string
+	advisory *utility.Advisory
+	level    string
+	fixed    []string
+}
+
+// coordinate identifies a package version in an OSV ecosystem.
+type coordinate struct {
+	ecosystem string
+	name      string
+	version   string
+}
+
+// Audit matches every plugin release, and the Maven projects embedded in its installed JARs (including libraries
+// shaded into it), against a local OSV database. Nothing is fetched, so it can run in sealed environments.
+// It fails when a vulnerability at or above the threshold severity is found; a threshold of "none" never fails.
+func Audit(manifest *lib.Manifest, wardrobe string, database string, threshold string) error {
+	limit := utility.SeverityRank(threshold)
+	if limit < 0 && threshold != "none" {
+		return fmt.Errorf("unknown severity %q (expected none or one of %s)", threshold, strings.Join(utility.Severities, ", "))
+	}
+
+	db, err := utility.LoadOSV(database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("[INFO] Loaded %d advisories from %s\n", db.Count, database)
+
+	keys := make([]string, 0, len(manifest.Plugins))
+	for key := range manifest.Plugins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var findings []finding
+	var checked int
+	for _, key := range keys {
+		plugin := manifest.Plugins[key]
+		owner, repo, _ := strings.Cut(key, "/")
+
+		packages := map[string]coordinate{
+			key + "@" + plugin.Tag: {"purl", "pkg:github/" + owner + "/" + repo, plugin.Tag},
+		}
+
+		dir, err := utility.Directory(manifest, wardrobe, plugin)
+		var paths []string
+		if err == nil {
+			paths, err = installed(dir, plugin)
+		}
+		if err != nil {
+			fmt.Printf("[WARN] %s is not installed; only its release was checked\n", key)
+		}
+
+		for _, path := range paths {
+			jar, err := utility.Inspect(path)
+			if err != nil {
+				fmt.Printf("[WARN] %s is not installed; only its release was checked\n", key)
+				break
+			}
+			for _, pom := range jar.Poms {
+				if pom.GroupID != "" && pom.ArtifactID != "" && pom.Version != "" {
+					name := pom.GroupID + ":" + pom.ArtifactID
+					packages[name+"@"+pom.Version] = coordinate{"Maven", name, pom.Version}
+				}
+			}
+		}
+
+		for coords, lookup := range packages {
+			checked++
+			for _, advisory := range db.Match(lookup.ecosystem, lookup.name, lookup.version) {
+				findings = append(findings, finding{
+					plugin:   key,
+					coords:   coords,
+					advisory: advisory,
+					level:    advisory.Level(),
+					fixed:    fixes(advisory, lookup.ecosystem, lookup.name),
+				})
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if rankA, rankB := utility.SeverityRank(a.level), utility.SeverityRank(b.level); rankA != rankB {
+			return rankA > rankB
+		}
+		if a.plugin != b.plugin {
+			return a.plugin < b.plugin
+		}
+		return a.advisory.ID < b.advisory.ID
+	})
+
+	counts := map[string]int{}
+	failing := 0
+	for _, found := range findings {
+		counts[found.level]++
+		if limit >= 0 && utility.SeverityRank(found.level) >= limit {
+			failing++
+		}
+
+		fmt.Printf("[VULN] %-8s %s in %s: %s", strings.ToUpper(found.level), found.advisory.ID, found.plugin, found.coords)
+		if found.advisory.Summary != "" {
+			fmt.Printf(" - %s", found.advisory.Summary)
+		}
+		fmt.Println()
+		if len(found.fixed) > 0 {
+			fmt.Printf("         fixed in %s\n", strings.Join(found.fixed, ", "))
+		}
+	}
+
+	var summary []string
+	for i := len(utility.Severities) - 1; i >= 0; i-- {
+		if count := counts[utility.Severities[i]]; count > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", count, utility.Severities[i]))
+		}
+	}
+	if len(findings) == 0 {
+		fmt.Printf("[INFO] No known vulnerabilities in %d packages across %d plugins\n", checked, len(keys))
+		return nil
+	}
+	fmt.Printf("[INFO] Found %d vulnerabilities in %d packages across %d plugins (%s)\n",
+		len(findings), checked, len(keys), strings.Join(summary, ", "))
+
+	if failing > 0 {
+		return fmt.Errorf("%d vulnerabilities at or above %s severity", failing, threshold)
+	}
+	return nil
+}
+
+// fixes lists the versions that fix an advisory for a package.
+func fixes(advisory *utility.Advisory, ecosystem string, name string) []string {
+	var fixed []string
+	for _, affected := range advisory.Affected {
+		if !strings.EqualFold(affected.Package.Name, name) && !strings.HasPrefix(strings.ToLower(affected.Package.Purl), strings.ToLower(name)) {
+			continue
+		}
+		if ecosystem != "purl" && !strings.EqualFold(affected.Package.Ecosystem, ecosystem) {
+			continue
+		}
+		for _, span := range affected.Ranges {
+			for _, event := range span.Events {
+				if event.Fixed != "" && span.Type != "GIT" {
+					fixed = append(fixed, event.Fixed)
+				}
+			}
+		}
+	}
+	return utility.Distinct(fixed)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"os"
+)
+
+// Check scans every JAR in each install target for duplicate classes and service registrations,
+// which Keycloak would otherwise reject at build time. Conflicts are reported against their owning plugins.
+func Check(manifest *lib.Manifest, wardrobe string) error {
+	targets := utility.Targets(manifest, wardrobe)
+
+	var total, scanned int
+	for _, name := range utility.TargetNames(targets) {
+		dir := targets[name]
+		if info, err := os.Stat(dir); dir == "" || err != nil || !info.IsDir() {
+			continue
+		}
+
+		jars, err := utility.Scan(dir)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s target: %w", name, err)
+		}
+		if len(jars) == 0 {
+			continue
+		}
+
+		scanned += len(jars)
+		total += report(name, dir, label(jars, utility.Managed(manifest, name, dir)))
+	}
+
+	if total == 0 {
+		fmt.Printf("[INFO] No conflicts found across %d JARs\n", scanned)
+		return nil
+	}
+	return fmt.Errorf("found %d conflicts", total)
+}
+
+// label keys scanned JARs by the plugin that owns them, or by path for unmanaged JARs.
+// A plugin that extracted several JARs labels each one with its path as well.
+func label(scanned map[string]*utility.Jar, owners map[string]string) map[string]*utility.Jar {
+	count := map[string]int{}
+	for file := range scanned {
+		if owner, managed := owners[file]; managed {
+			count[owner]++
+		}
+	}
+
+	jars := make(map[string]*utility.Jar, len(scanned))
+	for file, jar := range scanned {
+		name, managed := owners[file]
+		switch {
+		case !managed:
+			name = file + " (unmanaged)"
+		case count[name] > 1:
+			name = fmt.Sprintf("%s (%s)", name, file)
+		}
+		jars[name] = jar
+	}
+	return jars
+}
+
+// report prints the conflicts between JARs in a single target and returns how many were found.
+func report(target string, dir string, jars map[string]*utility.Jar) int {
+	conflicts := utility.Conflicts(jars)
+	if len(conflicts) == 0 {
+		return 0
+	}
+
+	fmt.Printf("[INFO] Conflicts in %s target (%s):\n", target, dir)
+
+	// Shaded JARs can clash on thousands of classes, so only show the first few per kind
+	shown := map[string]int{}
+	for _, conflict := range conflicts {
+		shown[conflict.Kind]++
+		if shown[conflict.Kind] <= 20 {
+			fmt.Printf("[CONFLICT] %s\n", conflict.Describe())
+		}
+	}
+	for kind, count := range shown {
+		if count > 20 {
+			fmt.Printf("[CONFLICT] ... and %d more duplicate %s entries\n", count-20, kind)
+		}
+	}
+
+	return len(conflicts)
+}
@@ -1,19 +1,32 @@
 package handlers
 
 import (
+	"cloakroom/lib"
 	"cloakroom/lib/utility"
 	"fmt"
 )
 
-// Clean removes all contents from the wardrobe directory.
-// It ensures the directory is emptied before restoring or other operations.
-func Clean(wardrobe string) error {
-	fmt.Printf("[INFO] Cleaning wardrobe directory: %s\n", wardrobe)
+// Clean removes all contents from the selected target directories, the wardrobe by default.
+// It ensures the directories are emptied before restoring or other operations.
+func Clean(manifest *lib.Manifest, wardrobe string, selected []string) error {
+	targets := utility.Targets(manifest, wardrobe)
+	names, err := utility.Select(targets, selected, utility.DefaultTarget)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		dir, err := utility.TargetDirectory(targets, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("[INFO] Cleaning %s directory: %s\n", name, dir)
 
-	if err := utility.Clean(wardrobe); err != nil {
-		return fmt.Errorf("failed to clean wardrobe directory: %w", err)
+		if err := utility.Clean(dir); err != nil {
+			return fmt.Errorf("failed to clean %s directory: %w", name, err)
+		}
 	}
 
-	fmt.Println("[INFO] Wardrobe directory cleaned successfully.")
+	fmt.Println("[INFO] Cleaned successfully.")
 	return nil
 }
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Compatibility checks each plugin's Keycloak constraint against the target Keycloak version.
+//
+// The target is, in order of preference: the keycloak argument, the manifest's keycloak version,
+// or the version detected from the distribution at home (falling back to the wardrobe's parent directory).
+// Mismatches are errors unless lenient is set, in which case they are only reported as warnings.
+func Compatibility(manifest *lib.Manifest, keycloak string, home string, wardrobe string, lenient bool) error {
+	constrained := make([]string, 0, len(manifest.Plugins))
+	for key, plugin := range manifest.Plugins {
+		if plugin.Keycloak != "" {
+			constrained = append(constrained, key)
+		}
+	}
+	if len(constrained) == 0 {
+		return nil
+	}
+	sort.Strings(constrained)
+
+	target := target(manifest, keycloak, home, wardrobe)
+	if target == "" {
+		fmt.Printf("[WARN] Keycloak version unknown; skipping compatibility checks for %d plugins.\n", len(constrained))
+		fmt.Println("       Set 'keycloak' in the manifest, pass --keycloak or set KC_HOME.")
+		return nil
+	}
+
+	level := "ERROR"
+	if lenient {
+		level = "WARN"
+	}
+
+	var incompatible int
+	for _, key := range constrained {
+		plugin := manifest.Plugins[key]
+		ok, err := utility.Satisfies(target, plugin.Keycloak)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", key, err)
+		}
+		if !ok {
+			incompatible++
+			fmt.Printf("[%s] %s requires Keycloak %s, but the target is %s\n", level, key, plugin.Keycloak, target)
+		}
+	}
+
+	if incompatible > 0 && !lenient {
+		return fmt.Errorf("%d plugins are incompatible with Keycloak %s (use --ignore-compatibility to continue)", incompatible, target)
+	}
+	return nil
+}
+
+// target resolves the Keycloak version plugins are checked against.
+func target(manifest *lib.Manifest, keycloak string, home string, wardrobe string) string {
+	if keycloak != "" {
+		return keycloak
+	}
+	if manifest.Keycloak != "" {
+		return manifest.Keycloak
+	}
+
+	candidates := []string{home}
+	if wardrobe != "" {
+		if absolute, err := filepath.Abs(wardrobe); err == nil {
+			candidates = append(candidates, filepath.Dir(absolute))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		if version, err := utility.DetectKeycloak(candidate); err == nil {
+			fmt.Printf("[INFO] Detected Keycloak %s in %s\n", version, candidate)
+			return version
+		}
+	}
+	return ""
+}
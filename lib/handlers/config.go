@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"io"
+)
+
+// Sources lists the manifests that were merged, in order of precedence, and every value one of them overrides.
+func Sources(resolved *utility.Resolved) {
+	fmt.Println("[INFO] Manifests, from lowest to highest precedence:")
+	for _, file := range resolved.Files {
+		fmt.Printf("  - %s\n", file)
+	}
+
+	if len(resolved.Overrides) == 0 {
+		fmt.Println("[INFO] No manifest overrides another.")
+		return
+	}
+
+	fmt.Println("[INFO] Overrides:")
+	for _, override := range resolved.Overrides {
+		fmt.Printf("  - %s: %s overrides %s\n", override.Path, override.File, override.Previous)
+	}
+}
+
+// Resolved writes the effective manifest, after merging and environment overrides, in one of utility.ManifestFormats.
+func Resolved(manifest *lib.Manifest, format string, out io.Writer) error {
+	settings, _ := utility.Encode(*manifest).(map[string]any)
+	if settings == nil {
+		settings = map[string]any{}
+	}
+
+	content, err := utility.Marshal(settings, format)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(content)
+	return err
+}
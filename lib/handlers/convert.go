@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// Convert writes the manifest in another format, next to it unless output names another file.
+// The new file is read back and compared with the source before anything else happens, and the source is only
+// deleted, with remove, once they match.
+func Convert(source string, format string, output string, force bool, remove bool) error {
+	format = strings.ToLower(format)
+	if output == "" {
+		output = strings.TrimSuffix(source, filepath.Ext(source)) + "." + format
+	}
+	if filepath.Clean(output) == filepath.Clean(source) {
+		return fmt.Errorf("%s is already a %s manifest", source, format)
+	}
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", output)
+	}
+
+	settings, err := utility.Settings(source)
+	if err != nil {
+		return err
+	}
+
+	manifest := &lib.Manifest{}
+	if err := utility.Decode(settings, manifest); err != nil {
+		return fmt.Errorf("failed to decode manifest %s: %w", source, err)
+	}
+
+	encoded, _ := utility.Encode(*manifest).(map[string]any)
+	if encoded == nil {
+		encoded = map[string]any{}
+	}
+	for _, key := range []string{utility.Wardrobe, utility.KeycloakHome} {
+		if value, found := settings[key]; found {
+			encoded[key] = value
+		}
+	}
+	if format == "json" {
+		encoded["$schema"] = utility.SchemaURL
+	}
+
+	content, err := utility.Marshal(encoded, format)
+	if err != nil {
+		return fmt.Errorf("can't write %s as %s: %w", source, format, err)
+	}
+	if directive := utility.SchemaDirectives[format]; directive != "" {
+		content = append([]byte(directive+utility.SchemaURL+"\n"), content...)
+	}
+
+	if err := os.WriteFile(output, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	if err := roundTrip(manifest, output); err != nil {
+		_ = os.Remove(output)
+		return err
+	}
+	fmt.Printf("[OK] Converted %s to %s\n", source, output)
+
+	if remove {
+		if err := os.Remove(source); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", source, err)
+		}
+		fmt.Printf("[INFO] Deleted %s\n", source)
+	} else if filepath.Dir(output) == filepath.Dir(source) && detected(source) && detected(output) {
+		fmt.Printf("[WARN] %s and %s are both in %s; pass --manifest to choose one, or delete the other.\n", source, output, filepath.Dir(source))
+	}
+	return nil
+}
+
+// detected reports whether a file is named like the manifests cloakroom looks for, e.g. cloakroom.yaml.
+func detected(file string) bool {
+	return strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) == utility.Cloakroom
+}
+
+// roundTrip reads a converted manifest back and checks that it describes the same manifest as the source.
+func roundTrip(manifest *lib.Manifest, output string) error {
+	settings, err := utility.Settings(output)
+	if err != nil {
+		return err
+	}
+
+	converted := &lib.Manifest{}
+	if err := utility.Decode(settings, converted); err != nil {
+		return fmt.Errorf("converted manifest %s can't be read back: %w", output, err)
+	}
+	if !reflect.DeepEqual(utility.Encode(*manifest), utility.Encode(*converted)) {
+		return fmt.Errorf("converted manifest %s doesn't read back the same as the source", output)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"context"
+	"crypto"
+	_ "crypto/sha256"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Formats lists the formats Export can render.
+var Formats = []string{"dockerfile", "sh", "make"}
+
+// export is a single plugin download, resolved for rendering.
+type export struct {
+	key      string
+	plugin   lib.Plugin
+	url      string
+	variable string
+	sha256   string
+}
+
+// ExportOptions controls how Export renders the manifest.
+type ExportOptions struct {
+	// Format is one of Formats.
+	Format string
+	// Providers is the directory the default target maps to where the snippet runs, e.g. inside the image.
+	Providers string
+	// SkipChecksum leaves out the SHA-256 that Docker and sha256sum verify, which takes downloading every plugin once.
+	SkipChecksum bool
+	// Source names the manifest in the generated header.
+	Source string
+}
+
+// Export writes the commands that install every plugin in the manifest without cloakroom:
+// ADD instructions for a Dockerfile or Containerfile, a POSIX shell script, or a Makefile.
+// URLs are built exactly as restore builds them, from the manifest host. Unless options.SkipChecksum is set,
+// every plugin is downloaded once to verify it against the manifest hash and record its SHA-256, and the export
+// fails if that isn't possible rather than producing unverified downloads.
+func Export(ctx context.Context, manifest *lib.Manifest, options ExportOptions, out io.Writer) error {
+	render, found := map[string]func(io.Writer, map[string]string, []export) error{
+		"dockerfile": dockerfile,
+		"sh":         shell,
+		"make":       makefile,
+	}[options.Format]
+	if !found {
+		return fmt.Errorf("unknown format %q (expected one of %s)", options.Format, strings.Join(Formats, ", "))
+	}
+
+	keys := make([]string, 0, len(manifest.Plugins))
+	for key := range manifest.Plugins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	directories := map[string]string{}
+	var exports []export
+	for _, key := range keys {
+		plugin := manifest.Plugins[key]
+		if utility.IsArchive(plugin) {
+			fmt.Fprintf(os.Stderr, "[WARN] Skipping %s: archives can't be exported, extract them with cloakroom restore\n", key)
+			continue
+		}
+
+		sources, err := utility.Sources(manifest, key, plugin)
+		if err != nil {
+			return err
+		}
+
+		target := utility.TargetOf(plugin)
+		dir := options.Providers
+		if target != utility.DefaultTarget {
+			if dir = manifest.Targets[target]; dir == "" {
+				return fmt.Errorf("unknown target %q for %s", target, key)
+			}
+		}
+
+		name := variable(target)
+		directories[name] = dir
+		exports = append(exports, export{key: key, plugin: plugin, url: sources[0], variable: name})
+	}
+
+	if !options.SkipChecksum {
+		if err := digests(ctx, manifest, exports); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(out, "# Generated by 'cloakroom export --format %s' from %s. Do not edit by hand.\n", options.Format, options.Source)
+	return render(out, directories, exports)
+}
+
+// digests downloads every export once, verifying the manifest hash, to record its SHA-256.
+func digests(ctx context.Context, manifest *lib.Manifest, exports []export) error {
+	scratch, err := os.MkdirTemp("", "cloakroom-export-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(scratch)
+	}()
+
+	progress := mpb.NewWithContext(ctx, mpb.WithOutput(os.Stderr))
+	defer progress.Wait()
+
+	transfer, err := utility.NewTransfer(manifest, progress)
+	if err != nil {
+		return fmt.Errorf("failed to configure downloads: %w", err)
+	}
+	transfer.Permissions = utility.Permissions{UID: -1, GID: -1}
+
+	for i := range exports {
+		sources, err := utility.Sources(manifest, exports[i].key, exports[i].plugin)
+		if err != nil {
+			return err
+		}
+
+		destination := filepath.Join(scratch, fmt.Sprintf("%d-%s", i, exports[i].plugin.Artifact))
+		if err := utility.Download(ctx, transfer, sources, destination, exports[i].plugin.Hash); err != nil {
+			return fmt.Errorf("downloading %s: %w", exports[i].key, err)
+		}
+
+		if exports[i].sha256, err = utility.Digest(destination, crypto.SHA256); err != nil {
+			return fmt.Errorf("failed to compute the SHA-256 of %s: %w", exports[i].key, err)
+		}
+	}
+	return nil
+}
+
+// dockerfile renders ADD instructions, verified with --checksum when the SHA-256 is known.
+func dockerfile(out io.Writer, directories map[string]string, exports []export) error {
+	for _, item := range exports {
+		flags := ""
+		if item.sha256 != "" {
+			flags = "--checksum=sha256:" + item.sha256 + " "
+		}
+
+		fmt.Fprintf(out, "# %s %s\n", item.key, item.plugin.Tag)
+		fmt.Fprintf(out, "ADD %s%s %s\n", flags, item.url, path.Join(directories[item.variable], item.plugin.Artifact))
+	}
+	return nil
+}
+
+// shell renders a POSIX shell script downloading each plugin with curl and verifying it with sha256sum.
+// Each target directory can be overridden through the environment.
+func shell(out io.Writer, directories map[string]string, exports []export) error {
+	fmt.Fprintln(out, "set -eu")
+	fmt.Fprintln(out)
+	for _, name := range sorted(directories) {
+		fmt.Fprintf(out, "%s=\"${%s:-%s}\"\n", name, name, directories[name])
+		fmt.Fprintf(out, "mkdir -p \"$%s\"\n", name)
+	}
+
+	for _, item := range exports {
+		destination := fmt.Sprintf("\"$%s\"/%s", item.variable, quote(item.plugin.Artifact))
+
+		fmt.Fprintf(out, "\n# %s %s\n", item.key, item.plugin.Tag)
+		fmt.Fprintf(out, "curl -fsSL -o %s.partial %s\n", destination, quote(item.url))
+		if item.sha256 != "" {
+			fmt.Fprintf(out, "echo %s %s.partial | sha256sum -c -\n", quote(item.sha256+" "), destination)
+		}
+		fmt.Fprintf(out, "mv %s.partial %s\n", destination, destination)
+	}
+	return nil
+}
+
+// makefile renders one file target per plugin, all prerequisites of a phony "plugins" target.
+func makefile(out io.Writer, directories map[string]string, exports []export) error {
+	for _, name := range sorted(directories) {
+		fmt.Fprintf(out, "%s ?= %s\n", name, escape(directories[name]))
+	}
+
+	files := make([]string, 0, len(exports))
+	for _, item := range exports {
+		files = append(files, fmt.Sprintf("$(%s)/%s", item.variable, escape(item.plugin.Artifact)))
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, ".PHONY: plugins")
+	fmt.Fprintf(out, "plugins: %s\n", strings.Join(files, " "))
+
+	for i, item := range exports {
+		fmt.Fprintf(out, "\n# %s %s\n", item.key, item.plugin.Tag)
+		fmt.Fprintf(out, "%s:\n", files[i])
+		fmt.Fprintln(out, "\tmkdir -p $(@D)")
+		fmt.Fprintf(out, "\tcurl -fsSL -o $@.partial %s\n", escape(quote(item.url)))
+		if item.sha256 != "" {
+			fmt.Fprintf(out, "\techo '%s  $@.partial' | sha256sum -c -\n", item.sha256)
+		}
+		fmt.Fprintln(out, "\tmv $@.partial $@")
+	}
+	return nil
+}
+
+// invalid matches characters that can't appear in shell and make variable names.
+var invalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// variable returns the shell and make variable holding a target's directory, e.g. PROVIDERS.
+func variable(target string) string {
+	return strings.ToUpper(invalid.ReplaceAllString(target, "_"))
+}
+
+// sorted returns the keys of a map, sorted.
+func sorted(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quote single-quotes a value for the shell.
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// escape protects dollar signs from make's variable expansion.
+func escape(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
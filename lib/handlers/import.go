@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"context"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ImportDockerfile merges the GitHub release assets downloaded by a Dockerfile into the manifest.
+// Plugins already in the manifest are kept unless force is set. With hash, every imported asset is
+// downloaded once so its SHA3-512 can be recorded.
+func ImportDockerfile(ctx context.Context, manifest *lib.Manifest, path string, hash bool, force bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	references, skipped, err := utility.ParseDockerfile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	for _, reason := range skipped {
+		fmt.Printf("[WARN] Skipped %s\n", reason)
+	}
+	if len(references) == 0 {
+		fmt.Printf("[INFO] No GitHub release downloads found in %s\n", path)
+		return nil
+	}
+
+	imported := map[string]utility.Reference{}
+	for _, reference := range references {
+		if previous, found := imported[reference.Key]; found {
+			fmt.Printf("[WARN] line %d: %s is already imported from line %d; only one artifact per repository is supported\n",
+				reference.Line, reference.Key, previous.Line)
+			continue
+		}
+
+		plugin, exists := manifest.Plugins[reference.Key]
+		if exists && plugin.Tag == reference.Tag && plugin.Artifact == reference.Artifact {
+			fmt.Printf("[SKIP] %s %s is already in the manifest\n", reference.Key, reference.Tag)
+			continue
+		}
+		if exists && !force {
+			fmt.Printf("[SKIP] %s is already in the manifest at %s (use --force to replace it with %s)\n",
+				reference.Key, plugin.Tag, reference.Tag)
+			continue
+		}
+
+		plugin.Tag = reference.Tag
+		plugin.Artifact = reference.Artifact
+		plugin.Hash = nil
+		if base := origin(reference); !sameHost(base, manifest.Host) && !contains(plugin.Mirrors, base) {
+			plugin.Mirrors = append(plugin.Mirrors, base)
+		}
+
+		imported[reference.Key] = reference
+		manifest.Plugins[reference.Key] = plugin
+		fmt.Printf("[INFO] Imported %s (release: %s, artifact: %s) from line %d\n",
+			reference.Key, reference.Tag, reference.Artifact, reference.Line)
+	}
+
+	if hash && len(imported) > 0 {
+		if err := checksums(ctx, manifest, imported); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("[INFO] Imported %d plugins from %s\n", len(imported), path)
+	return nil
+}
+
+// checksums downloads each imported asset from the URL the Dockerfile used and records its hash in the manifest.
+func checksums(ctx context.Context, manifest *lib.Manifest, imported map[string]utility.Reference) error {
+	scratch, err := os.MkdirTemp("", "cloakroom-import-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(scratch)
+	}()
+
+	progress := mpb.NewWithContext(ctx)
+	defer progress.Wait()
+
+	transfer, err := utility.NewTransfer(manifest, progress)
+	if err != nil {
+		return fmt.Errorf("failed to configure downloads: %w", err)
+	}
+	transfer.Permissions = utility.Permissions{UID: -1, GID: -1}
+
+	for key, reference := range imported {
+		destination := filepath.Join(scratch, strings.ReplaceAll(key, "/", "_")+"_"+reference.Artifact)
+		if err := utility.Download(ctx, transfer, []string{reference.URL}, destination, nil); err != nil {
+			return fmt.Errorf("failed to download %s for hashing: %w", key, err)
+		}
+
+		checksum, err := utility.Checksum(destination)
+		if err != nil {
+			return err
+		}
+
+		plugin := manifest.Plugins[key]
+		plugin.Hash = &checksum
+		manifest.Plugins[key] = plugin
+	}
+	return nil
+}
+
+// origin returns the scheme and host a reference was downloaded from, usable as a mirror.
+func origin(reference utility.Reference) string {
+	parsed, err := url.Parse(reference.URL)
+	if err != nil {
+		return "https://" + reference.Host
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
+
+// sameHost reports whether a mirror base points at the manifest host, which is already tried first.
+func sameHost(base string, host string) bool {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	return strings.EqualFold(strings.TrimSuffix(base, "/"), strings.TrimSuffix(host, "/"))
+}
+
+// contains reports whether values includes value.
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// identity is what ImportWardrobe learned about a single JAR.
+type identity struct {
+	file string
+	hash string
+	// key and tag locate the release asset the JAR was matched against, or the best guess when unmatched.
+	key string
+	tag string
+	// matched is set when a release asset with the same name and digest was found.
+	matched bool
+	notes   []string
+}
+
+// ImportWardrobe identifies the JARs in a providers directory and writes a draft manifest to output.
+// Each JAR is hashed and its origin guessed from MANIFEST.MF and bundled Maven metadata; unless offline,
+// the manifest's host and mirrors are searched for a release asset with the same name and digest.
+// Matched JARs become plugin entries; everything else is listed as comments for review.
+func ImportWardrobe(ctx context.Context, manifest *lib.Manifest, dir string, output string, offline bool, force bool) error {
+	if _, err := os.Stat(output); err == nil && !force {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", output)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	client, err := utility.Client(manifest.Network)
+	if err != nil {
+		return fmt.Errorf("failed to configure downloads: %w", err)
+	}
+
+	var identities []identity
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jar") {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		found, err := identify(ctx, manifest, client, filepath.Join(dir, entry.Name()), offline)
+		if err != nil {
+			return err
+		}
+		identities = append(identities, found)
+	}
+
+	if err := os.WriteFile(output, []byte(draft(manifest, dir, identities)), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	var matched int
+	for _, found := range identities {
+		if found.matched {
+			matched++
+		}
+	}
+	fmt.Printf("[INFO] Identified %d of %d JARs; draft manifest written to %s\n", matched, len(identities), output)
+	return nil
+}
+
+// identify hashes a JAR, guesses where it was released from and, unless offline, looks for the matching asset.
+func identify(ctx context.Context, manifest *lib.Manifest, client *http.Client, path string, offline bool) (identity, error) {
+	file := filepath.Base(path)
+	hash, err := utility.Checksum(path)
+	if err != nil {
+		return identity{}, err
+	}
+	found := identity{file: file, hash: hash}
+
+	jar, err := utility.Inspect(path)
+	if err != nil {
+		found.notes = append(found.notes, "not a readable JAR")
+		return found, nil
+	}
+	if jar.Title != "" || jar.Version != "" {
+		found.notes = append(found.notes, strings.TrimSpace(fmt.Sprintf("declares %s %s", jar.Title, jar.Version)))
+	}
+
+	origins, tags := utility.Origins(jar, file), utility.Tags(jar, file)
+	if len(origins) == 0 {
+		found.notes = append(found.notes, "no GitHub repository found in MANIFEST.MF or Maven metadata")
+		return found, nil
+	}
+	found.key = origins[0]
+	if len(tags) == 0 {
+		found.notes = append(found.notes, "no version found to guess the release tag from")
+		return found, nil
+	}
+	found.tag = tags[0]
+
+	if offline {
+		found.notes = append(found.notes, "not verified against any release (--offline)")
+		return found, nil
+	}
+
+	bases := append([]string{manifest.Host}, manifest.Mirrors...)
+	for _, key := range origins {
+		for _, tag := range tags {
+			for _, base := range bases {
+				if strings.TrimSpace(base) == "" {
+					continue
+				}
+
+				source, err := utility.Source(base, manifest.Host, key, lib.Plugin{Tag: tag, Artifact: file})
+				if err != nil {
+					continue
+				}
+
+				fmt.Printf("[INFO] %s: probing %s\n", file, source)
+				attempt, cancel := context.WithTimeout(ctx, utility.Duration(manifest.Network.Timeout, utility.DefaultTimeout))
+				remote, exists, err := utility.RemoteChecksum(attempt, client, source)
+				cancel()
+
+				switch {
+				case err != nil:
+					found.notes = append(found.notes, fmt.Sprintf("could not check %s: %v", source, err))
+				case exists && remote == hash:
+					found.key, found.tag, found.matched = key, tag, true
+					return found, nil
+				case exists:
+					found.key, found.tag = key, tag
+					found.notes = append(found.notes, fmt.Sprintf("%s exists but its digest differs", source))
+				}
+			}
+		}
+	}
+
+	found.notes = append(found.notes, fmt.Sprintf("no release asset named %s matched its digest", file))
+	return found, nil
+}
+
+// draft renders a YAML manifest with the matched JARs as plugins and the rest commented out for review.
+func draft(manifest *lib.Manifest, dir string, identities []identity) string {
+	version, host := manifest.Version, manifest.Host
+	if version == "" {
+		version = "1.0"
+	}
+	if host == "" {
+		host = "github.com"
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Draft manifest generated by 'cloakroom import wardrobe %s'.\n", dir)
+	builder.WriteString("# Review the entries below, then rename this file to cloakroom.yaml or merge it into your manifest.\n")
+	fmt.Fprintf(&builder, "version: %s\n", strconv.Quote(version))
+	fmt.Fprintf(&builder, "host: %s\n", strconv.Quote(host))
+	if len(manifest.Mirrors) > 0 {
+		builder.WriteString("mirrors:\n")
+		for _, mirror := range manifest.Mirrors {
+			fmt.Fprintf(&builder, "  - %s\n", strconv.Quote(mirror))
+		}
+	}
+
+	var matched, unknown []identity
+	listed := map[string]string{}
+	for _, found := range identities {
+		if found.matched && listed[found.key] == "" {
+			listed[found.key] = found.file
+			matched = append(matched, found)
+			continue
+		}
+		if found.matched {
+			found.notes = append(found.notes, fmt.Sprintf("%s is already listed for %s", found.key, listed[found.key]))
+		}
+		unknown = append(unknown, found)
+	}
+
+	if len(matched) == 0 {
+		builder.WriteString("plugins: {}\n")
+	} else {
+		builder.WriteString("plugins:\n")
+	}
+	for _, found := range matched {
+		fmt.Fprintf(&builder, "  %s:\n", strconv.Quote(found.key))
+		fmt.Fprintf(&builder, "    tag: %s\n", strconv.Quote(found.tag))
+		fmt.Fprintf(&builder, "    artifact: %s\n", strconv.Quote(found.file))
+		fmt.Fprintf(&builder, "    hash: %s\n", strconv.Quote(found.hash))
+	}
+
+	for _, found := range unknown {
+		fmt.Fprintf(&builder, "\n  # UNKNOWN: %s\n", found.file)
+		for _, note := range found.notes {
+			fmt.Fprintf(&builder, "  #   %s\n", note)
+		}
+		key, tag := found.key, found.tag
+		if key == "" {
+			key = "owner/repo"
+		}
+		if tag == "" {
+			tag = "TODO"
+		}
+		fmt.Fprintf(&builder, "  # %s:\n", strconv.Quote(key))
+		fmt.Fprintf(&builder, "  #   tag: %s\n", strconv.Quote(tag))
+		fmt.Fprintf(&builder, "  #   artifact: %s\n", strconv.Quote(found.file))
+		fmt.Fprintf(&builder, "  #   hash: %s\n", strconv.Quote(found.hash))
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Inspect prints the manifest metadata and SPI service registrations of an installed plugin.
+func Inspect(manifest *lib.Manifest, key string, wardrobe string) error {
+	plugin, exists := manifest.Plugins[key]
+	if !exists {
+		return fmt.Errorf("plugin %s not found in the manifest", key)
+	}
+
+	dir, err := utility.Directory(manifest, wardrobe, plugin)
+	if err != nil {
+		return err
+	}
+
+	paths, err := installed(dir, plugin)
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s (run 'cloakroom restore' first?): %w", key, err)
+	}
+
+	for _, destination := range paths {
+		jar, err := utility.Inspect(destination)
+		if err != nil {
+			return fmt.Errorf("failed to inspect %s (run 'cloakroom restore' first?): %w", key, err)
+		}
+
+		fmt.Printf("[INFO] Inspecting %s: %s\n", key, destination)
+		describe(jar)
+	}
+	return nil
+}
+
+// installed returns the JARs a plugin installed into dir: its artifact, or the JARs extracted from its archive.
+func installed(dir string, plugin lib.Plugin) ([]string, error) {
+	if !utility.IsArchive(plugin) {
+		return []string{filepath.Join(dir, plugin.Artifact)}, nil
+	}
+
+	receipt, err := utility.ReadReceipt(dir, plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, file := range receipt.Files {
+		if strings.HasSuffix(file.Path, ".jar") {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(file.Path)))
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no JARs were extracted from %s", plugin.Artifact)
+	}
+	return paths, nil
+}
+
+// describe prints what a JAR declares about itself, indented to sit under a plugin entry.
+func describe(jar *utility.Jar) {
+	if jar.Title != "" {
+		fmt.Printf("    - title:    %s\n", jar.Title)
+	}
+	if jar.Version != "" {
+		fmt.Printf("    - version:  %s\n", jar.Version)
+	}
+
+	if len(jar.Services) == 0 {
+		fmt.Println("    - services: none")
+		return
+	}
+
+	fmt.Println("    - services:")
+	for _, name := range jar.Interfaces() {
+		fmt.Printf("        %s\n", name)
+		for _, provider := range jar.Services[name] {
+			fmt.Printf("          * %s\n", provider)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+)
+
+// Lint checks the manifest against the policy file and reports each violation with its location in the manifest.
+// It does nothing when there is no policy.
+func Lint(manifest *lib.Manifest, policyFile string, resolved *utility.Resolved) error {
+	if policyFile == "" {
+		fmt.Println("[INFO] No policy configured.")
+		return nil
+	}
+
+	policy, err := utility.LoadPolicy(policyFile)
+	if err != nil {
+		return err
+	}
+
+	violations, err := utility.Evaluate(policy, manifest, resolved.Origin)
+	if err != nil {
+		return fmt.Errorf("invalid policy %s: %w", policyFile, err)
+	}
+
+	for _, violation := range violations {
+		fmt.Printf("[POLICY] %s: %s: %s (%s)\n", violation.Where, violation.Path, violation.Message, violation.Rule)
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("manifest breaks %d rules of policy %s", len(violations), policyFile)
+	}
+
+	fmt.Printf("[INFO] Manifest complies with policy %s\n", policyFile)
+	return nil
+}
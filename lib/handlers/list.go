@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"cloakroom/lib"
+	"cloakroom/lib/utility"
 	"fmt"
 )
 
 // List outputs all plugins defined in the manifest.
-func List(manifest *lib.Manifest) error {
+// With details, it also describes the SPI services each installed JAR provides.
+func List(manifest *lib.Manifest, wardrobe string, details bool) error {
 	plugins := manifest.Plugins
 	if len(plugins) == 0 {
 		fmt.Println("[INFO] No plugins defined in the manifest.")
@@ -21,6 +23,39 @@ func List(manifest *lib.Manifest) error {
 		if plugin.Hash != nil {
 			fmt.Printf("    - hash:     %s\n", *plugin.Hash)
 		}
+		if utility.IsArchive(plugin) {
+			fmt.Printf("    - archive:  %s\n", plugin.Archive)
+		}
+		if plugin.Target != "" {
+			fmt.Printf("    - target:   %s\n", plugin.Target)
+		}
+		if plugin.Keycloak != "" {
+			fmt.Printf("    - keycloak: %s\n", plugin.Keycloak)
+		}
+		if details {
+			dir, err := utility.Directory(manifest, wardrobe, plugin)
+			var paths []string
+			if err == nil {
+				paths, err = installed(dir, plugin)
+			}
+
+			var jars []*utility.Jar
+			for _, path := range paths {
+				var jar *utility.Jar
+				if jar, err = utility.Inspect(path); err != nil {
+					break
+				}
+				jars = append(jars, jar)
+			}
+
+			if err != nil {
+				fmt.Println("    - details:  not installed")
+			} else {
+				for _, jar := range jars {
+					describe(jar)
+				}
+			}
+		}
 		fmt.Println()
 	}
 
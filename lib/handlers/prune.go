@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Prune removes files the manifest does not manage from the selected targets, the wardrobe by default.
+// With dryRun, it only reports what would be removed.
+func Prune(manifest *lib.Manifest, wardrobe string, selected []string, dryRun bool) error {
+	targets := utility.Targets(manifest, wardrobe)
+	names, err := utility.Select(targets, selected, utility.DefaultTarget)
+	if err != nil {
+		return err
+	}
+
+	var pruned int
+	for _, name := range names {
+		dir, err := utility.TargetDirectory(targets, name)
+		if err != nil {
+			return err
+		}
+		unmanaged, err := utility.Unmanaged(dir, utility.Managed(manifest, name, dir))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range unmanaged {
+			path := filepath.Join(dir, file)
+			pruned++
+
+			if dryRun {
+				fmt.Printf("[DRY-RUN] Would remove %s\n", path)
+				continue
+			}
+
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			fmt.Printf("[INFO] Removed %s\n", path)
+		}
+	}
+
+	if pruned == 0 {
+		fmt.Println("[INFO] Nothing to prune.")
+	}
+	return nil
+}
@@ -3,11 +3,14 @@ package handlers
 import (
 	"cloakroom/lib"
 	"cloakroom/lib/utility"
+	"context"
 	"fmt"
 	"path/filepath"
+	"strconv"
 )
 
 // Remove removes a plugin from the manifest and optionally deletes its files.
+// The post-remove hooks are left to PostRemove, once the manifest has been saved.
 func Remove(manifest *lib.Manifest, artifact string, wardrobe string, purge bool) error {
 	plugin, exists := manifest.Plugins[artifact]
 	if !exists {
@@ -18,13 +21,34 @@ func Remove(manifest *lib.Manifest, artifact string, wardrobe string, purge bool
 	fmt.Printf("[INFO] Removed plugin from manifest: %s\n", artifact)
 
 	if purge {
-		destination := filepath.Join(wardrobe, plugin.Artifact)
-		err := utility.Remove(destination)
+		dir, err := utility.Directory(manifest, wardrobe, plugin)
+		if err != nil {
+			return err
+		}
+
+		destination := filepath.Join(dir, plugin.Artifact)
+		if utility.IsArchive(plugin) {
+			destination = filepath.Join(dir, utility.ReceiptName(plugin))
+			err = utility.Purge(dir, plugin)
+		} else {
+			err = utility.Remove(destination)
+		}
 		if err != nil {
 			return fmt.Errorf("failed to purge plugin files for %s: %w", artifact, err)
 		}
 		fmt.Printf("[INFO] Successfully purged plugin files: %s\n", destination)
 	}
-
 	return nil
 }
+
+// PostRemove runs the manifest's post-remove hooks for a plugin Remove removed.
+func PostRemove(ctx context.Context, manifest *lib.Manifest, artifact string, plugin lib.Plugin, wardrobe string, purge bool) error {
+	return utility.Hook(ctx, "post-remove", manifest.Hooks.PostRemove, map[string]string{
+		"CLOAKROOM_WARDROBE": wardrobe,
+		"CLOAKROOM_PLUGIN":   artifact,
+		"CLOAKROOM_TAG":      plugin.Tag,
+		"CLOAKROOM_ARTIFACT": plugin.Artifact,
+		"CLOAKROOM_TARGET":   utility.TargetOf(plugin),
+		"CLOAKROOM_PURGED":   strconv.FormatBool(purge),
+	})
+}
@@ -4,39 +4,226 @@ import (
 	"cloakroom/lib"
 	"cloakroom/lib/utility"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/vbauerster/mpb/v8"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// RestoreOptions controls how Restore treats the wardrobe.
+type RestoreOptions struct {
+	// Clean empties the wardrobe and every other target the manifest installs into before restoring.
+	Clean bool
+	// Force overwrites plugins that already exist.
+	Force bool
+	// SkipCheck skips checking the wardrobe for conflicting plugins afterwards.
+	SkipCheck bool
+	// Build runs "kc.sh build" when the contents of the install targets changed.
+	Build bool
+	// Home is the Keycloak installation used to locate kc.sh, if known.
+	Home string
+}
+
 // Restore iterates through each plugin, downloading it if it does not exist.
-func Restore(manifest *lib.Manifest, wardrobe string, clean bool, force bool) error {
-	ctx := context.Background()
-	progress := mpb.New()
+// If ctx is cancelled, in-flight downloads are abandoned and a summary of what was completed is printed.
+//
+// The manifest's pre-restore hooks run first. Each plugin's post-install hooks run once it has been downloaded,
+// then the wardrobe is checked for conflicts, the post-restore hooks run and, if requested, Keycloak is rebuilt.
+func Restore(ctx context.Context, manifest *lib.Manifest, wardrobe string, options RestoreOptions) error {
+	progress := mpb.NewWithContext(ctx)
+	transfer, err := utility.NewTransfer(manifest, progress)
+	if err != nil {
+		return fmt.Errorf("failed to configure downloads: %w", err)
+	}
+
+	environment := map[string]string{"CLOAKROOM_WARDROBE": wardrobe}
+	if err := utility.Hook(ctx, "pre-restore", manifest.Hooks.PreRestore, environment); err != nil {
+		return err
+	}
+
+	// Downloads are compared with what was installed before, so re-downloading identical files doesn't rebuild
+	var before map[string]string
+	if options.Build {
+		if before, err = snapshot(manifest, wardrobe); err != nil {
+			return err
+		}
+	}
 
-	if clean {
-		fmt.Printf("[INFO] Cleaning wardrobe directory: %s\n", wardrobe)
-		if err := utility.Clean(wardrobe); err != nil {
-			return fmt.Errorf("failed to clean wardrobe directory: %w", err)
+	if options.Clean {
+		if err := clean(manifest, wardrobe); err != nil {
+			return err
 		}
 	}
 
 	var group sync.WaitGroup
+	var mutex sync.Mutex
+	var changed []string
+	var restored atomic.Int32
 	errs := make(chan error, len(manifest.Plugins))
 
 	for key, plugin := range manifest.Plugins {
 		group.Add(1)
 		go func(key string, plugin lib.Plugin) {
 			defer group.Done()
-			errs <- utility.Restore(ctx, manifest.Host, wardrobe, key, plugin, force, progress)
+
+			sources, err := utility.Sources(manifest, key, plugin)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			dir, err := utility.Directory(manifest, wardrobe, plugin)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			downloaded, err := utility.Install(ctx, transfer, manifest, sources, dir, key, plugin, options.Force)
+			if downloaded {
+				mutex.Lock()
+				changed = append(changed, key)
+				mutex.Unlock()
+			}
+			if err == nil {
+				restored.Add(1)
+			}
+			errs <- err
 		}(key, plugin)
 	}
 
 	group.Wait()
 	close(errs)
+	progress.Wait()
+
+	var failures []error
+	for err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("[WARN] Interrupted: %d of %d plugins restored; incomplete downloads were removed.\n",
+			restored.Load(), len(manifest.Plugins))
+		return fmt.Errorf("restore interrupted: %w", ctx.Err())
+	}
+
+	if len(failures) > 0 {
+		return errors.Join(failures...)
+	}
+
+	sort.Strings(changed)
+	for _, key := range changed {
+		if err := install(ctx, manifest, wardrobe, key, manifest.Plugins[key]); err != nil {
+			return err
+		}
+	}
+
+	if !options.SkipCheck {
+		if err := Check(manifest, wardrobe); err != nil {
+			return err
+		}
+	}
+
+	environment["CLOAKROOM_CHANGED"] = strings.Join(changed, " ")
+	if err := utility.Hook(ctx, "post-restore", manifest.Hooks.PostRestore, environment); err != nil {
+		return err
+	}
+
+	if options.Build {
+		after, err := snapshot(manifest, wardrobe)
+		if err != nil {
+			return err
+		}
+		return build(ctx, wardrobe, options.Home, !maps.Equal(before, after))
+	}
+	return nil
+}
+
+// clean empties the wardrobe and the directory of every target a plugin is installed into.
+// Directories that don't exist yet are left for the install to create.
+func clean(manifest *lib.Manifest, wardrobe string) error {
+	names := []string{utility.DefaultTarget}
+	for _, plugin := range manifest.Plugins {
+		names = append(names, utility.TargetOf(plugin))
+	}
+	sort.Strings(names)
+
+	targets := utility.Targets(manifest, wardrobe)
+	for _, name := range utility.Distinct(names) {
+		dir, err := utility.TargetDirectory(targets, name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		fmt.Printf("[INFO] Cleaning %s directory: %s\n", name, dir)
+		if err := utility.Clean(dir); err != nil {
+			return fmt.Errorf("failed to clean %s directory: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// snapshot returns the checksum of every file in the install targets, keyed by its path.
+func snapshot(manifest *lib.Manifest, wardrobe string) (map[string]string, error) {
+	files := map[string]string{}
+	for _, dir := range utility.Targets(manifest, wardrobe) {
+		if dir == "" {
+			continue
+		}
+
+		checksums, err := utility.Snapshot(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		for path, checksum := range checksums {
+			files[filepath.Join(dir, path)] = checksum
+		}
+	}
+	return files, nil
+}
+
+// install runs a plugin's post-install hooks after it has been downloaded.
+func install(ctx context.Context, manifest *lib.Manifest, wardrobe string, key string, plugin lib.Plugin) error {
+	dir, err := utility.Directory(manifest, wardrobe, plugin)
+	if err != nil {
+		return err
+	}
+
+	return utility.Hook(ctx, "post-install", plugin.Hooks.PostInstall, map[string]string{
+		"CLOAKROOM_WARDROBE":    wardrobe,
+		"CLOAKROOM_PLUGIN":      key,
+		"CLOAKROOM_TAG":         plugin.Tag,
+		"CLOAKROOM_ARTIFACT":    plugin.Artifact,
+		"CLOAKROOM_TARGET":      utility.TargetOf(plugin),
+		"CLOAKROOM_DESTINATION": filepath.Join(dir, plugin.Artifact),
+	})
+}
+
+// build runs "kc.sh build" so Keycloak picks up the new providers, but only if they changed.
+func build(ctx context.Context, wardrobe string, home string, changed bool) error {
+	if !changed {
+		fmt.Println("[SKIP] Providers unchanged; not running kc.sh build.")
+		return nil
+	}
+
+	script, err := utility.KcSh(home, wardrobe)
+	if err != nil {
+		return err
+	}
 
-	if len(errs) > 0 {
-		return <-errs
+	fmt.Printf("[INFO] Running %s build\n", script)
+	if err := utility.Run(ctx, os.Environ(), script, "build"); err != nil {
+		return fmt.Errorf("kc.sh build failed: %w", err)
 	}
 	return nil
 }
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SbomFormats lists the formats Sbom can write.
+var SbomFormats = []string{"cyclonedx-json", "spdx-json"}
+
+// Sbom writes a software bill of materials for the plugins in the manifest, named after the manifest.
+// Each plugin is a component identified by its GitHub purl, with its download URL, hashes and the licenses
+// declared inside its JAR. Libraries shaded into a plugin are listed under it from their embedded pom.properties.
+// Plugins that aren't installed are described from the manifest alone.
+func Sbom(manifest *lib.Manifest, wardrobe string, format string, name string, out io.Writer) error {
+	build, found := map[string]func(string, []utility.Component) map[string]any{
+		"cyclonedx-json": utility.CycloneDX,
+		"spdx-json":      utility.SPDX,
+	}[format]
+	if !found {
+		return fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(SbomFormats, ", "))
+	}
+
+	keys := make([]string, 0, len(manifest.Plugins))
+	for key := range manifest.Plugins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	components := make([]utility.Component, 0, len(keys))
+	for _, key := range keys {
+		component, err := component(manifest, wardrobe, key, manifest.Plugins[key])
+		if err != nil {
+			return err
+		}
+		components = append(components, component)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(build(name, components))
+}
+
+// component describes a single plugin, inspecting its installed JARs when present.
+func component(manifest *lib.Manifest, wardrobe string, key string, plugin lib.Plugin) (utility.Component, error) {
+	owner, repo, _ := strings.Cut(key, "/")
+	component := utility.Component{
+		Name:    repo,
+		Group:   owner,
+		Version: plugin.Tag,
+		Purl:    utility.GitHubPurl(key, plugin.Tag),
+		Hashes:  map[string]string{},
+	}
+
+	sources, err := utility.Sources(manifest, key, plugin)
+	if err != nil {
+		return component, err
+	}
+	component.URL = sources[0]
+	if plugin.Hash != nil {
+		component.Hashes["SHA3-512"] = strings.ToLower(*plugin.Hash)
+	}
+
+	dir, err := utility.Directory(manifest, wardrobe, plugin)
+	var paths []string
+	if err == nil {
+		paths, err = installed(dir, plugin)
+	}
+	if err == nil && !utility.IsArchive(plugin) {
+		_, err = os.Stat(paths[0])
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "[WARN] %s is not installed; describing it from the manifest only\n", key)
+		return component, nil
+	}
+
+	// The hashes describe the downloaded artifact, which for archives isn't what was installed
+	if !utility.IsArchive(plugin) {
+		for algorithm, hash := range map[string]crypto.Hash{"SHA256": crypto.SHA256, "SHA3-512": crypto.SHA3_512} {
+			if component.Hashes[algorithm], err = utility.Digest(paths[0], hash); err != nil {
+				return component, err
+			}
+		}
+	}
+
+	nested := map[string]utility.Component{}
+	for _, path := range paths {
+		jar, err := utility.Inspect(path)
+		if err != nil {
+			return component, fmt.Errorf("failed to inspect %s: %w", key, err)
+		}
+
+		component.Licenses = append(component.Licenses, jar.Licenses...)
+		project := jar.Project(path)
+		for _, pom := range jar.Poms {
+			if project != nil && pom.Dir == project.Dir {
+				component.Licenses = append(component.Licenses, pom.Licenses...)
+				continue
+			}
+			if pom.GroupID == "" || pom.ArtifactID == "" {
+				continue
+			}
+
+			library := utility.Component{
+				Name:     pom.ArtifactID,
+				Group:    pom.GroupID,
+				Version:  pom.Version,
+				Purl:     utility.MavenPurl(pom),
+				Licenses: pom.Licenses,
+			}
+			nested[library.Purl] = library
+		}
+	}
+	component.Licenses = utility.Distinct(component.Licenses)
+
+	for _, purl := range sortedPurls(nested) {
+		component.Nested = append(component.Nested, nested[purl])
+	}
+	return component, nil
+}
+
+// sortedPurls returns the package URLs of a set of components, sorted.
+func sortedPurls(components map[string]utility.Component) []string {
+	purls := make([]string, 0, len(components))
+	for purl := range components {
+		purls = append(purls, purl)
+	}
+	sort.Strings(purls)
+	return purls
+}
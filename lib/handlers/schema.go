@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"cloakroom/lib/utility"
+	"encoding/json"
+	"io"
+)
+
+// Schema writes the JSON Schema of the manifest.
+func Schema(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(utility.Schema())
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"cloakroom/lib/utility"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Serve exposes the mirror directory over HTTP using the GitHub releases layout,
+// along with a JSON index of the available releases at /index.json.
+// The server shuts down gracefully when ctx is cancelled.
+func Serve(ctx context.Context, addr string, root string) error {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return fmt.Errorf("mirror root is not a directory: %s", root)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /index.json", func(w http.ResponseWriter, r *http.Request) {
+		releases, err := utility.Index(root)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"releases": releases})
+	})
+	mux.Handle("GET /{owner}/{repo}/releases/download/{tag}/{artifact}", http.FileServer(http.Dir(root)))
+
+	server := &http.Server{Addr: addr, Handler: logged(mux), ReadHeaderTimeout: 30 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("[INFO] Serving %s on %s\n", root, addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("mirror server failed: %w", err)
+	}
+
+	fmt.Println("[INFO] Mirror server stopped.")
+	return nil
+}
+
+// logged prints one line per request served by the mirror.
+func logged(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		fmt.Printf("[HTTP] %s %s %d\n", r.Method, r.URL.Path, recorder.status)
+	})
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
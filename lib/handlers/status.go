@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Status reports, for each selected target, which plugins are installed, missing or modified,
+// and which files in the target are not managed by the manifest. All targets are shown by default.
+func Status(manifest *lib.Manifest, wardrobe string, selected []string) error {
+	targets := utility.Targets(manifest, wardrobe)
+	names, err := utility.Select(targets, selected, utility.TargetNames(targets)...)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		dir, err := utility.TargetDirectory(targets, name)
+		if err != nil {
+			return err
+		}
+		managed := utility.Managed(manifest, name, dir)
+		fmt.Printf("[INFO] %s target: %s\n", name, dir)
+
+		files := make([]string, 0, len(managed))
+		for file := range managed {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			key := managed[file]
+			fmt.Printf("  * %-40s %s (%s)\n", key, file, state(dir, file, manifest.Plugins[key]))
+		}
+
+		unmanaged, err := utility.Unmanaged(dir, managed)
+		if err != nil {
+			return err
+		}
+		for _, file := range unmanaged {
+			fmt.Printf("  ? %s (unmanaged)\n", file)
+		}
+
+		if len(files) == 0 && len(unmanaged) == 0 {
+			fmt.Println("  (empty)")
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// state describes whether an installed file is present and matches its expected hash.
+// Files extracted from an archive are checked against the hash recorded in its receipt.
+func state(dir string, file string, plugin lib.Plugin) string {
+	path := filepath.Join(dir, file)
+	if _, err := os.Stat(path); err != nil {
+		if utility.IsArchive(plugin) && file == utility.ReceiptName(plugin) {
+			return "not extracted"
+		}
+		return "missing"
+	}
+
+	hash := plugin.Hash
+	if utility.IsArchive(plugin) {
+		hash = nil
+		if receipt, err := utility.ReadReceipt(dir, plugin); err == nil {
+			for _, extracted := range receipt.Files {
+				if filepath.FromSlash(extracted.Path) == file {
+					hash = &extracted.Hash
+				}
+			}
+		}
+	}
+
+	if hash != nil {
+		if err := utility.Verify(path, *hash); err != nil {
+			return "modified"
+		}
+	}
+	return "installed"
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vbauerster/mpb/v8"
+	"sync"
+)
+
+// Sync populates a mirror directory with every plugin in the manifest,
+// laid out as {owner}/{repo}/releases/download/{tag}/{artifact} so it can be served by Serve.
+func Sync(ctx context.Context, manifest *lib.Manifest, root string, force bool) error {
+	progress := mpb.NewWithContext(ctx)
+	transfer, err := utility.NewTransfer(manifest, progress)
+	if err != nil {
+		return fmt.Errorf("failed to configure downloads: %w", err)
+	}
+
+	var group sync.WaitGroup
+	errs := make(chan error, len(manifest.Plugins))
+
+	for key, plugin := range manifest.Plugins {
+		group.Add(1)
+		go func(key string, plugin lib.Plugin) {
+			defer group.Done()
+
+			sources, err := utility.Sources(manifest, key, plugin)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			directory, err := utility.MirrorDirectory(root, key, plugin)
+			if err != nil {
+				errs <- err
+				return
+			}
+			_, err = utility.Restore(ctx, transfer, sources, directory, key, plugin, force)
+			errs <- err
+		}(key, plugin)
+	}
+
+	group.Wait()
+	close(errs)
+	progress.Wait()
+
+	var failures []error
+	for err := range errs {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+
+	if ctx.Err() != nil {
+		fmt.Printf("[WARN] Interrupted: %d of %d plugins mirrored; incomplete downloads were removed.\n",
+			len(manifest.Plugins)-len(failures), len(manifest.Plugins))
+		return fmt.Errorf("sync interrupted: %w", ctx.Err())
+	}
+
+	if len(failures) == 0 {
+		fmt.Printf("[INFO] Mirror is up to date: %s\n", root)
+	}
+	return errors.Join(failures...)
+}
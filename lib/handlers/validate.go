@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"cloakroom/lib"
+	"cloakroom/lib/utility"
+	"fmt"
+)
+
+// Validate checks every manifest strictly, then the manifest they resolve to once interpolated, and reports each
+// problem with the file and line it comes from.
+func Validate(resolved *utility.Resolved) error {
+	count := 0
+	report := func(file string, problems []utility.Problem) {
+		for _, problem := range problems {
+			where := utility.Where(file, problem.Path)
+			if len(problem.Path) == 0 {
+				fmt.Printf("[INVALID] %s: %s\n", where, problem.Message)
+			} else {
+				fmt.Printf("[INVALID] %s: %s: %s\n", where, problem.Path, problem.Message)
+			}
+		}
+		count += len(problems)
+	}
+
+	for _, file := range resolved.Files {
+		problems, err := utility.Structure(file)
+		if err != nil {
+			return err
+		}
+		report(file, problems)
+	}
+
+	manifest := &lib.Manifest{}
+	_ = utility.Decode(resolved.Settings, manifest) // Decoding errors were reported above
+
+	// Values that can't be interpolated are left as written, so they aren't checked any further
+	problems := utility.Interpolate(manifest)
+	failed := make(map[string]bool, len(problems))
+	for _, problem := range problems {
+		failed[problem.Path.String()] = true
+	}
+	for _, problem := range utility.Check(manifest) {
+		if !failed[problem.Path.String()] {
+			problems = append(problems, problem)
+		}
+	}
+	utility.Sort(problems)
+	for _, problem := range problems {
+		report(resolved.Origin(problem.Path), []utility.Problem{problem})
+	}
+
+	if count > 0 {
+		return fmt.Errorf("manifest %s failed validation", resolved.Primary())
+	}
+	return nil
+}
@@ -1,15 +1,95 @@
 package lib
 
+import "time"
+
 // Manifest represents the top-level structure of the cloakroom manifest
 type Manifest struct {
-	Version string            `mapstructure:"version"`
+	Version  string            `mapstructure:"version"`
+	Host     string            `mapstructure:"host"`
+	Keycloak string            `mapstructure:"keycloak"`
+	Mirrors  []string          `mapstructure:"mirrors"`
+	Plugins  map[string]Plugin `mapstructure:"plugins"`
+	Network  Network           `mapstructure:"network"`
+	Hooks    Hooks             `mapstructure:"hooks"`
+	Files    Files             `mapstructure:"files"`
+	Targets  map[string]string `mapstructure:"targets"`
+
+	// Include lists other manifests merged in before this one, relative to it. This manifest takes precedence.
+	Include []string `mapstructure:"include"`
+
+	Profiles map[string]Profile `mapstructure:"profiles"`
+}
+
+// Profile represents the changes an environment makes to the manifest, selected with --profile or CLOAKROOM_PROFILE.
+// Plugins are added, or override the fields they set in the manifest's entry with the same key, after those listed
+// in remove are removed.
+type Profile struct {
 	Host    string            `mapstructure:"host"`
 	Plugins map[string]Plugin `mapstructure:"plugins"`
+	Remove  []string          `mapstructure:"remove"`
 }
 
 // Plugin represents the configuration for each plugin denoted by a "user/repo" key
 type Plugin struct {
-	Tag      string  `mapstructure:"tag"`
-	Artifact string  `mapstructure:"artifact"`
-	Hash     *string `mapstructure:"hash"`
+	Tag      string      `mapstructure:"tag"`
+	Artifact string      `mapstructure:"artifact"`
+	Hash     *string     `mapstructure:"hash"`
+	Mirrors  []string    `mapstructure:"mirrors"`
+	Keycloak string      `mapstructure:"keycloak"`
+	Hooks    PluginHooks `mapstructure:"hooks"`
+	Target   string      `mapstructure:"target"`
+
+	// Archive is the format of an artifact to extract rather than install as-is: zip, tar, tar.gz or tgz.
+	Archive         string   `mapstructure:"archive"`
+	Extract         []string `mapstructure:"extract"`
+	StripComponents int      `mapstructure:"strip-components"`
+}
+
+// Network represents the connection settings shared by every download
+type Network struct {
+	Proxy      string `mapstructure:"proxy"`
+	NoProxy    string `mapstructure:"no-proxy"`
+	CABundle   string `mapstructure:"ca-bundle"`
+	ClientCert string `mapstructure:"client-cert"`
+	ClientKey  string `mapstructure:"client-key"`
+	MinTLS     string `mapstructure:"min-tls"`
+
+	ConnectTimeout *time.Duration `mapstructure:"connect-timeout"`
+	TLSTimeout     *time.Duration `mapstructure:"tls-timeout"`
+	HeaderTimeout  *time.Duration `mapstructure:"header-timeout"`
+	Timeout        *time.Duration `mapstructure:"timeout"`
+	StallTimeout   *time.Duration `mapstructure:"stall-timeout"`
+}
+
+// Hooks represents the local commands run at the stages of the plugin lifecycle that apply to the whole manifest.
+type Hooks struct {
+	PreRestore  []string `mapstructure:"pre-restore"`
+	PostRestore []string `mapstructure:"post-restore"`
+	PostRemove  []string `mapstructure:"post-remove"`
+}
+
+// PluginHooks represents the local commands run for a single plugin. Only post-install applies to a plugin.
+type PluginHooks struct {
+	PostInstall []string `mapstructure:"post-install"`
+}
+
+// Files represents the mode and ownership applied to every installed artifact
+type Files struct {
+	Mode    string `mapstructure:"mode"`
+	DirMode string `mapstructure:"dir-mode"`
+	Owner   string `mapstructure:"owner"`
+	Group   string `mapstructure:"group"`
+}
+
+// Policy represents the rules a manifest must follow, kept in a policy file separate from the manifest.
+// Every rule is optional; an empty policy allows everything but file:// sources.
+type Policy struct {
+	Owners          []string `mapstructure:"owners"`
+	Hosts           []string `mapstructure:"hosts"`
+	Schemes         []string `mapstructure:"schemes"`
+	RequireHash     bool     `mapstructure:"require-hash"`
+	RequireKeycloak bool     `mapstructure:"require-keycloak"`
+	DenyBranchTags  bool     `mapstructure:"deny-branch-tags"`
+	Branches        []string `mapstructure:"branches"`
+	TagPattern      string   `mapstructure:"tag-pattern"`
 }
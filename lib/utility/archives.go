@@ -0,0 +1,475 @@
+package utility
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"cloakroom/lib"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Receipt records the files extracted from an archive plugin, so they can be verified and purged individually.
+// It is stored next to the extracted files, see ReceiptName.
+type Receipt struct {
+	Plugin   string        `json:"plugin"`
+	Tag      string        `json:"tag"`
+	Artifact string        `json:"artifact"`
+	Files    []ReceiptFile `json:"files"`
+}
+
+// ReceiptFile is a single extracted file, relative to the target directory, and its SHA3-512 hash.
+type ReceiptFile struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// IsArchive reports whether a plugin's artifact is an archive to be extracted rather than installed as-is.
+func IsArchive(plugin lib.Plugin) bool {
+	return plugin.Archive != ""
+}
+
+// ReceiptName returns the file name of the receipt kept for an archive plugin.
+func ReceiptName(plugin lib.Plugin) string {
+	return "." + plugin.Artifact + ".cloakroom.json"
+}
+
+// ReadReceipt loads the receipt of an archive plugin from its target directory.
+func ReadReceipt(dir string, plugin lib.Plugin) (*Receipt, error) {
+	content, err := os.ReadFile(filepath.Join(dir, ReceiptName(plugin)))
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &Receipt{}
+	if err := json.Unmarshal(content, receipt); err != nil {
+		return nil, fmt.Errorf("invalid receipt for %s: %w", plugin.Artifact, err)
+	}
+	return receipt, nil
+}
+
+// Install puts a plugin into its target directory: archives are downloaded and extracted, other artifacts are
+// downloaded as-is (see Restore). It reports whether anything was installed, as opposed to skipped.
+// Archives never overwrite the artifacts of the manifest's other plugins in the same target, unless forced.
+func Install(
+	ctx context.Context,
+	transfer *Transfer,
+	manifest *lib.Manifest,
+	sources []string,
+	dir string,
+	key string,
+	plugin lib.Plugin,
+	force bool,
+) (bool, error) {
+	if !IsArchive(plugin) {
+		return Restore(ctx, transfer, sources, dir, key, plugin, force)
+	}
+
+	if receipt, err := ReadReceipt(dir, plugin); err == nil {
+		if !force && present(dir, receipt) {
+			fmt.Printf("[SKIP] Plugin already extracted: %s (use --force to overwrite)\n", key)
+			return false, nil
+		}
+		if err := Purge(dir, plugin); err != nil {
+			return false, err
+		}
+	}
+
+	archive := filepath.Join(dir, "."+plugin.Artifact+".download")
+	defer func() {
+		_ = os.Remove(archive)
+	}()
+
+	if err := Download(ctx, transfer, sources, archive, plugin.Hash); err != nil {
+		return false, fmt.Errorf("downloading %s -> %s: %w", key, dir, err)
+	}
+
+	files, err := Extract(archive, dir, plugin, artifacts(manifest, key, plugin), transfer.Permissions, force)
+	if err != nil {
+		return false, fmt.Errorf("extracting %s: %w", key, err)
+	}
+
+	receipt := Receipt{Plugin: key, Tag: plugin.Tag, Artifact: plugin.Artifact, Files: files}
+	content, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	path := filepath.Join(dir, ReceiptName(plugin))
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return false, fmt.Errorf("failed to write receipt %s: %w", path, err)
+	}
+	if err := transfer.Permissions.Apply(path); err != nil {
+		return false, err
+	}
+
+	fmt.Printf("[OK] Extracted %d files from %s -> %s\n", len(files), key, dir)
+	return true, nil
+}
+
+// Purge removes every file recorded in an archive plugin's receipt, along with the receipt itself
+// and any directories left empty. Recorded paths that would lie outside dir are skipped.
+func Purge(dir string, plugin lib.Plugin) error {
+	receipt, err := ReadReceipt(dir, plugin)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, file := range receipt.Files {
+		relative := filepath.FromSlash(file.Path)
+		if !filepath.IsLocal(relative) {
+			fmt.Printf("[WARN] Not removing %q: the receipt of %s points outside %s\n", file.Path, plugin.Artifact, dir)
+			continue
+		}
+
+		if err := remove(dir, relative); err != nil {
+			return err
+		}
+	}
+
+	return os.Remove(filepath.Join(dir, ReceiptName(plugin)))
+}
+
+// remove deletes a file relative to dir, then its parent directories up to dir, stopping at the first
+// that isn't empty.
+func remove(dir string, relative string) error {
+	path := filepath.Join(dir, relative)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+
+	prune(dir, filepath.Dir(path))
+	return nil
+}
+
+// prune removes a directory inside dir and its parents up to dir, stopping at the first that isn't empty.
+func prune(dir string, parent string) {
+	for ; parent != filepath.Clean(dir); parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break
+		}
+	}
+}
+
+// artifacts returns the artifacts of the manifest's plugins installed as-is into the same target as plugin,
+// other than key itself, mapped to the plugin that installs each one.
+func artifacts(manifest *lib.Manifest, key string, plugin lib.Plugin) map[string]string {
+	owners := map[string]string{}
+	for other, installed := range manifest.Plugins {
+		if other != key && !IsArchive(installed) && TargetOf(installed) == TargetOf(plugin) {
+			owners[installed.Artifact] = other
+		}
+	}
+	return owners
+}
+
+// claimed returns the files recorded by the receipts in dir of every archive plugin other than plugin,
+// mapped to the plugin that extracted each one.
+func claimed(dir string, plugin lib.Plugin) map[string]string {
+	receipts, _ := filepath.Glob(filepath.Join(dir, ".*.cloakroom.json"))
+
+	owners := map[string]string{}
+	for _, name := range receipts {
+		if filepath.Base(name) == ReceiptName(plugin) {
+			continue
+		}
+
+		content, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		receipt := &Receipt{}
+		if json.Unmarshal(content, receipt) != nil {
+			continue
+		}
+		for _, file := range receipt.Files {
+			owners[path.Clean(file.Path)] = receipt.Plugin
+		}
+	}
+	return owners
+}
+
+// present reports whether every file in a receipt still exists.
+func present(dir string, receipt *Receipt) bool {
+	for _, file := range receipt.Files {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file.Path))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// entry is a regular file inside an archive, independent of the archive format.
+type entry struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+// Extract unpacks the files of an archive selected by the plugin's extract patterns into dir,
+// after removing strip-components leading path elements. Entries that would land outside dir are rejected,
+// and so are files another archive plugin extracted and the artifacts other plugins install as-is, mapped to their
+// plugin in artifacts, unless force is set.
+// If extraction fails, the files and directories it created are removed.
+func Extract(
+	archive string,
+	dir string,
+	plugin lib.Plugin,
+	artifacts map[string]string,
+	permissions Permissions,
+	force bool,
+) ([]ReceiptFile, error) {
+	selectors, err := compile(plugin.Extract)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := claimed(dir, plugin)
+	var files []ReceiptFile
+	var created []string
+	var attempted string
+	visit := func(item entry) error {
+		name, ok := strip(item.name, plugin.StripComponents)
+		if !ok || !selected(name, selectors) {
+			return nil
+		}
+
+		// Zip-slip protection: every extracted path must stay inside the target directory
+		relative := filepath.FromSlash(name)
+		if !filepath.IsLocal(relative) {
+			return fmt.Errorf("archive entry %q escapes the target directory", item.name)
+		}
+
+		if owner, found := owners[name]; found {
+			if !force {
+				return fmt.Errorf("%s was extracted by %s (use --force to overwrite it)", name, owner)
+			}
+			fmt.Printf("[WARN] Overwriting %s, extracted by %s\n", name, owner)
+		}
+		if owner, found := artifacts[name]; found {
+			if !force {
+				return fmt.Errorf("%s is the artifact of %s (use --force to overwrite it)", name, owner)
+			}
+			fmt.Printf("[WARN] Overwriting %s, the artifact of %s\n", name, owner)
+		}
+
+		destination := filepath.Join(dir, relative)
+		_, statErr := os.Stat(destination)
+		attempted = relative
+		hash, err := write(item, destination, permissions)
+		if err != nil {
+			return err
+		}
+
+		if statErr != nil {
+			created = append(created, relative)
+		}
+		files = append(files, ReceiptFile{Path: name, Hash: hash})
+		return nil
+	}
+
+	switch strings.ToLower(plugin.Archive) {
+	case "zip":
+		err = walkZip(archive, visit)
+	case "tar":
+		err = walkTar(archive, false, visit)
+	case "tar.gz", "tgz":
+		err = walkTar(archive, true, visit)
+	default:
+		err = fmt.Errorf("unsupported archive format %q (expected zip, tar or tar.gz)", plugin.Archive)
+	}
+	if err != nil {
+		// Don't leave a half-extracted archive behind. Files that already existed stay, overwritten or not.
+		for _, relative := range created {
+			_ = remove(dir, relative)
+		}
+		if attempted != "" {
+			prune(dir, filepath.Dir(filepath.Join(dir, attempted)))
+		}
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files in %s matched %v", plugin.Artifact, plugin.Extract)
+	}
+	return files, nil
+}
+
+// walkZip calls visit for every regular file in a zip archive.
+func walkZip(archive string, visit func(entry) error) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer func(reader *zip.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if err := visit(entry{name: file.Name, open: file.Open}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTar calls visit for every regular file in a tar archive, optionally gzip-compressed.
+// Links and other special entries are skipped.
+func walkTar(archive string, compressed bool, visit func(entry) error) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer func(file *os.File) {
+		_ = file.Close()
+	}(file)
+
+	var stream io.Reader = file
+	if compressed {
+		decompressed, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer func(decompressed *gzip.Reader) {
+			_ = decompressed.Close()
+		}(decompressed)
+		stream = decompressed
+	}
+
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		open := func() (io.ReadCloser, error) { return io.NopCloser(reader), nil }
+		if err := visit(entry{name: header.Name, open: open}); err != nil {
+			return err
+		}
+	}
+}
+
+// write copies an archive entry to destination through a partial file, returning its SHA3-512 hash.
+func write(item entry, destination string, permissions Permissions) (string, error) {
+	if err := permissions.MkdirAll(filepath.Dir(destination)); err != nil {
+		return "", err
+	}
+
+	source, err := item.open()
+	if err != nil {
+		return "", err
+	}
+	defer func(source io.ReadCloser) {
+		_ = source.Close()
+	}(source)
+
+	partial := destination + ".partial"
+	out, err := os.Create(partial)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = os.Remove(partial)
+	}()
+
+	sha3 := crypto.SHA3_512.New()
+	_, err = io.Copy(io.MultiWriter(out, sha3), source)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to extract %s: %w", item.name, err)
+	}
+
+	if err := permissions.Apply(partial); err != nil {
+		return "", err
+	}
+	if err := os.Rename(partial, destination); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sha3.Sum(nil)), nil
+}
+
+// strip removes the first n elements of an archive path, reporting false if nothing is left.
+// Absolute and escaping paths are returned unchanged, so that Extract rejects them.
+func strip(name string, n int) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return name, true
+	}
+
+	parts := strings.Split(name, "/")
+	if n >= len(parts) {
+		return "", false
+	}
+	return strings.Join(parts[n:], "/"), true
+}
+
+// compile turns extract patterns into regular expressions. "*" matches within a path element and "**" across them.
+// No patterns selects every file.
+func compile(patterns []string) ([]*regexp.Regexp, error) {
+	selectors := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		var expression strings.Builder
+		expression.WriteString("^")
+		for i := 0; i < len(pattern); i++ {
+			switch {
+			case strings.HasPrefix(pattern[i:], "**/"):
+				expression.WriteString("(.*/)?")
+				i += 2
+			case strings.HasPrefix(pattern[i:], "**"):
+				expression.WriteString(".*")
+				i++
+			case pattern[i] == '*':
+				expression.WriteString("[^/]*")
+			case pattern[i] == '?':
+				expression.WriteString("[^/]")
+			default:
+				expression.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		}
+		expression.WriteString("$")
+
+		selector, err := regexp.Compile(expression.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid extract pattern %q: %w", pattern, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// selected reports whether a path matches any selector, or whether there are no selectors at all.
+func selected(name string, selectors []*regexp.Regexp) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	for _, selector := range selectors {
+		if selector.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
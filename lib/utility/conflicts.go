@@ -0,0 +1,108 @@
+package utility
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Conflict is a class or service registration provided by more than one JAR.
+type Conflict struct {
+	// Kind is either "class" or "service".
+	Kind string
+	// Name is the fully-qualified class name, or "interface -> implementation" for services.
+	Name string
+	// Owners lists the plugins (or file names, for unmanaged JARs) that provide Name.
+	Owners []string
+}
+
+// Scan inspects every JAR inside a directory, including those extracted into subdirectories,
+// keyed by their path relative to it.
+func Scan(dir string) (map[string]*Jar, error) {
+	jars := map[string]*Jar{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".jar") {
+			return nil
+		}
+		if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		jar, err := Inspect(path)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		jars[relative] = jar
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", dir, err)
+	}
+	return jars, nil
+}
+
+// Conflicts finds classes and service registrations that appear in more than one JAR.
+// JARs are keyed by the label used to report them, e.g. the owning "owner/repo".
+func Conflicts(jars map[string]*Jar) []Conflict {
+	classes := map[string][]string{}
+	services := map[string][]string{}
+
+	for owner, jar := range jars {
+		for _, class := range jar.Classes {
+			classes[class] = append(classes[class], owner)
+		}
+		for name, providers := range jar.Services {
+			for _, provider := range providers {
+				entry := name + " -> " + provider
+				services[entry] = append(services[entry], owner)
+			}
+		}
+	}
+
+	var conflicts []Conflict
+	collect := func(kind string, index map[string][]string) {
+		for name, owners := range index {
+			owners = unique(owners)
+			if len(owners) > 1 {
+				conflicts = append(conflicts, Conflict{Kind: kind, Name: name, Owners: owners})
+			}
+		}
+	}
+	collect("class", classes)
+	collect("service", services)
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind > conflicts[j].Kind
+		}
+		return conflicts[i].Name < conflicts[j].Name
+	})
+	return conflicts
+}
+
+// unique sorts values and drops duplicates.
+func unique(values []string) []string {
+	sort.Strings(values)
+	result := values[:0]
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// Describe summarises a conflict in a single line.
+func (conflict Conflict) Describe() string {
+	return fmt.Sprintf("duplicate %s %s in %s", conflict.Kind, conflict.Name, strings.Join(conflict.Owners, ", "))
+}
@@ -1,4 +1,38 @@
 package utility
 
+import "time"
+
 const Cloakroom = "cloakroom"
 const Wardrobe = "wardrobe"
+
+// KeycloakHome is read from the KC_HOME environment variable to detect the target Keycloak version.
+const KeycloakHome = "kc_home"
+
+// Environment lists the manifest keys that may also be set through environment variables,
+// e.g. CLOAKROOM_NETWORK_PROXY for network.proxy.
+var Environment = []string{
+	"network.proxy",
+	"network.no-proxy",
+	"network.ca-bundle",
+	"network.client-cert",
+	"network.client-key",
+	"network.min-tls",
+	"network.connect-timeout",
+	"network.tls-timeout",
+	"network.header-timeout",
+	"network.timeout",
+	"network.stall-timeout",
+	"files.mode",
+	"files.dir-mode",
+	"files.owner",
+	"files.group",
+}
+
+// Default network timeouts, used when the manifest leaves them unset. Setting any of them to "0s" disables it.
+const (
+	DefaultConnectTimeout = 30 * time.Second
+	DefaultTLSTimeout     = 30 * time.Second
+	DefaultHeaderTimeout  = 60 * time.Second
+	DefaultTimeout        = 30 * time.Minute
+	DefaultStallTimeout   = 60 * time.Second
+)
@@ -0,0 +1,236 @@
+package utility
+
+import (
+	"bytes"
+	"cloakroom/lib"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFormats lists the formats a manifest can be written in, which are also its file extensions.
+var ManifestFormats = []string{"hcl", "ini", "json", "toml", "yaml"}
+
+// FormatOf returns the format of a manifest file from its extension, e.g. yaml for cloakroom.yml.
+func FormatOf(file string) string {
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(file), "."))
+	if format == "yml" {
+		return "yaml"
+	}
+	return format
+}
+
+// manifestType is the type manifest settings are decoded into.
+var manifestType = reflect.TypeOf(lib.Manifest{})
+
+// Marshal renders manifest settings, as produced by Encode, in one of ManifestFormats.
+// HCL and INI are written by hand: plugins become `plugins "owner/repo" {` blocks and [plugins.owner/repo] sections,
+// which viper's own encoders can't produce.
+func Marshal(settings map[string]any, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(settings); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(settings); err != nil {
+			return nil, err
+		}
+	case "toml":
+		if err := toml.NewEncoder(&buffer).Encode(settings); err != nil {
+			return nil, err
+		}
+	case "hcl":
+		hclBody(&buffer, settings, manifestType, "")
+	case "ini":
+		if err := iniSections(&buffer, settings); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(ManifestFormats, ", "))
+	}
+	return buffer.Bytes(), nil
+}
+
+// ordered returns the keys of settings decoded into type t: fields in declaration order first,
+// then any other keys (plugin names, target names) sorted.
+func ordered(settings map[string]any, t reflect.Type) []string {
+	rank := map[string]int{"$schema": -1}
+	if t != nil && t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			rank[t.Field(i).Tag.Get("mapstructure")] = i
+		}
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, knownA := rank[keys[i]]
+		b, knownB := rank[keys[j]]
+		switch {
+		case knownA && knownB:
+			return a < b
+		case knownA != knownB:
+			return knownA
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// child returns the type the value under key is decoded into, given the type of its parent, or nil if unknown.
+func child(t reflect.Type, key string) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == nil:
+		return nil
+	case t.Kind() == reflect.Map:
+		return t.Elem()
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Tag.Get("mapstructure") == key {
+				return t.Field(i).Type
+			}
+		}
+	}
+	return nil
+}
+
+// identifier matches the HCL keys that need no quotes.
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// hclKey quotes an HCL key or label when it isn't a plain identifier, e.g. "acme/theme".
+func hclKey(key string) string {
+	if identifier.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// hclBody writes the attributes of an HCL body, then its blocks. Objects of objects, such as plugins,
+// become one labelled block per entry.
+func hclBody(buffer *bytes.Buffer, settings map[string]any, t reflect.Type, indent string) {
+	var nested []string
+	for _, key := range ordered(settings, t) {
+		if _, ok := settings[key].(map[string]any); ok {
+			nested = append(nested, key)
+			continue
+		}
+		fmt.Fprintf(buffer, "%s%s = %s\n", indent, hclKey(key), hclValue(settings[key]))
+	}
+
+	for _, key := range nested {
+		object, nestedType := settings[key].(map[string]any), child(t, key)
+		if labelled(object) {
+			for _, label := range ordered(object, nestedType) {
+				fmt.Fprintf(buffer, "\n%s%s %s {\n", indent, hclKey(key), strconv.Quote(label))
+				hclBody(buffer, object[label].(map[string]any), child(nestedType, label), indent+"  ")
+				fmt.Fprintf(buffer, "%s}\n", indent)
+			}
+			continue
+		}
+
+		fmt.Fprintf(buffer, "\n%s%s {\n", indent, hclKey(key))
+		hclBody(buffer, object, nestedType, indent+"  ")
+		fmt.Fprintf(buffer, "%s}\n", indent)
+	}
+}
+
+// labelled reports whether an object holds only objects, and so is written as labelled blocks.
+func labelled(object map[string]any) bool {
+	for _, value := range object {
+		if _, ok := value.(map[string]any); !ok {
+			return false
+		}
+	}
+	return len(object) > 0
+}
+
+// hclValue renders a scalar or a list of scalars.
+func hclValue(value any) string {
+	switch value := value.(type) {
+	case string:
+		return strconv.Quote(value)
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, hclValue(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
+
+// iniSections writes top-level keys without a section, then a section per object, named by its dotted path.
+// viper splits section names on dots, so keys containing one can't be written, nor list items containing a comma.
+func iniSections(buffer *bytes.Buffer, settings map[string]any) error {
+	file := ini.Empty()
+
+	var add func(section string, values map[string]any, t reflect.Type) error
+	add = func(section string, values map[string]any, t reflect.Type) error {
+		var nested []string
+		for _, key := range ordered(values, t) {
+			value := values[key]
+			if _, ok := value.(map[string]any); ok {
+				nested = append(nested, key)
+				continue
+			}
+
+			text := fmt.Sprint(value)
+			if list, ok := value.([]any); ok {
+				items := make([]string, 0, len(list))
+				for _, item := range list {
+					if strings.Contains(fmt.Sprint(item), ",") {
+						return fmt.Errorf("%s.%s: list item %q contains a comma, which INI lists can't represent", section, key, item)
+					}
+					items = append(items, fmt.Sprint(item))
+				}
+				text = strings.Join(items, ",")
+			}
+
+			if _, err := file.Section(section).NewKey(key, text); err != nil {
+				return err
+			}
+		}
+
+		// Sections are written in the order they are created, so nested ones follow their parent
+		for _, key := range nested {
+			if strings.Contains(key, ".") {
+				return fmt.Errorf("key %q contains a dot, which INI section names can't represent", key)
+			}
+			name := key
+			if section != "" {
+				name = section + "." + key
+			}
+			if err := add(name, values[key].(map[string]any), child(t, key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := add("", settings, manifestType); err != nil {
+		return err
+	}
+	_, err := file.WriteTo(buffer)
+	return err
+}
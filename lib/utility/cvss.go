@@ -0,0 +1,72 @@
+package utility
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// cvssWeights holds the CVSS v3 base metric weights. Privileges required is adjusted for scope in CVSS3.
+var cvssWeights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// CVSS3 computes the base score of a CVSS v3.0 or v3.1 vector such as "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H".
+func CVSS3(vector string) (float64, error) {
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/") {
+		if name, value, found := strings.Cut(part, ":"); found && name != "CVSS" {
+			metrics[name] = value
+		}
+	}
+
+	values := map[string]float64{}
+	for name, weights := range cvssWeights {
+		weight, found := weights[metrics[name]]
+		if !found {
+			return 0, fmt.Errorf("invalid CVSS v3 vector %q: bad or missing %s", vector, name)
+		}
+		values[name] = weight
+	}
+
+	changed := metrics["S"] == "C"
+	if !changed && metrics["S"] != "U" {
+		return 0, fmt.Errorf("invalid CVSS v3 vector %q: bad or missing S", vector)
+	}
+	if changed && metrics["PR"] == "L" {
+		values["PR"] = 0.68
+	}
+	if changed && metrics["PR"] == "H" {
+		values["PR"] = 0.5
+	}
+
+	iss := 1 - (1-values["C"])*(1-values["I"])*(1-values["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+
+	exploitability := 8.22 * values["AV"] * values["AC"] * values["PR"] * values["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+// roundUp rounds to one decimal place, upwards, as defined by the CVSS v3.1 specification.
+func roundUp(value float64) float64 {
+	integer := int(math.Round(value * 100000))
+	if integer%10000 == 0 {
+		return float64(integer) / 100000
+	}
+	return (math.Floor(float64(integer)/10000) + 1) / 10
+}
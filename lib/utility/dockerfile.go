@@ -0,0 +1,168 @@
+package utility
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Reference is a GitHub release asset downloaded by a Dockerfile.
+type Reference struct {
+	// Line is the line of the Dockerfile instruction the URL appears in.
+	Line     int
+	URL      string
+	Host     string
+	Key      string
+	Tag      string
+	Artifact string
+}
+
+// release matches GitHub release download URLs, on github.com or any other host using the same layout.
+var release = regexp.MustCompile(`https?://([^/\s"']+)/([^/\s"']+)/([^/\s"']+)/releases/download/([^/\s"']+)/([^/\s"'\\;&|>)]+)`)
+
+// variable matches $NAME and ${NAME} references to ARG and ENV values.
+var variable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ParseDockerfile finds the GitHub release assets fetched by ADD instructions and by curl or wget in RUN instructions.
+// Defaults from ARG and ENV instructions are expanded; URLs that still depend on unknown variables are reported in skipped.
+func ParseDockerfile(reader io.Reader) (references []Reference, skipped []string, err error) {
+	values := map[string]string{}
+
+	parsed, err := instructions(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, instruction := range parsed {
+		keyword, rest, _ := strings.Cut(strings.TrimSpace(instruction.text), " ")
+		rest = strings.TrimSpace(rest)
+
+		switch strings.ToUpper(keyword) {
+		case "ARG", "ENV":
+			define(values, rest)
+		case "ADD", "RUN":
+			if strings.ToUpper(keyword) == "RUN" && !strings.Contains(rest, "curl") && !strings.Contains(rest, "wget") {
+				continue
+			}
+
+			for _, field := range strings.Fields(expand(rest, values)) {
+				field = strings.Trim(field, `"',[]`)
+				if !strings.Contains(field, "/releases/download/") {
+					continue
+				}
+				if strings.Contains(field, "$") {
+					skipped = append(skipped, fmt.Sprintf("line %d: %s (unresolved variable)", instruction.line, field))
+					continue
+				}
+
+				reference, ok := parseRelease(field)
+				if !ok {
+					skipped = append(skipped, fmt.Sprintf("line %d: %s (not a release asset URL)", instruction.line, field))
+					continue
+				}
+				reference.Line = instruction.line
+				references = append(references, reference)
+			}
+		}
+	}
+
+	return references, skipped, nil
+}
+
+// parseRelease splits a release download URL into its host, owner/repo key, tag and artifact.
+func parseRelease(raw string) (Reference, bool) {
+	match := release.FindStringSubmatch(raw)
+	if match == nil {
+		return Reference{}, false
+	}
+
+	tag, err := url.PathUnescape(match[4])
+	if err != nil {
+		return Reference{}, false
+	}
+	artifact, err := url.PathUnescape(match[5])
+	if err != nil {
+		return Reference{}, false
+	}
+
+	return Reference{
+		URL:      match[0],
+		Host:     match[1],
+		Key:      match[2] + "/" + match[3],
+		Tag:      tag,
+		Artifact: artifact,
+	}, true
+}
+
+// instruction is a single Dockerfile instruction with its line continuations joined.
+type instruction struct {
+	line int
+	text string
+}
+
+// instructions splits a Dockerfile into instructions, dropping comments and joining continued lines.
+func instructions(reader io.Reader) ([]instruction, error) {
+	var result []instruction
+	var current strings.Builder
+	start := 0
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if current.Len() == 0 {
+			if line == "" {
+				continue
+			}
+			start = number
+		}
+
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+
+		current.WriteString(line)
+		result = append(result, instruction{line: start, text: current.String()})
+		current.Reset()
+	}
+
+	if current.Len() > 0 {
+		result = append(result, instruction{line: start, text: current.String()})
+	}
+	return result, scanner.Err()
+}
+
+// define records the values set by an ARG or ENV instruction, in both the NAME=value and legacy "ENV NAME value" forms.
+func define(values map[string]string, rest string) {
+	if !strings.Contains(rest, "=") {
+		if name, value, found := strings.Cut(rest, " "); found {
+			values[name] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+		return
+	}
+
+	for _, field := range strings.Fields(rest) {
+		if name, value, found := strings.Cut(field, "="); found {
+			values[name] = expand(strings.Trim(value, `"'`), values)
+		}
+	}
+}
+
+// expand replaces references to known variables, leaving unknown ones untouched.
+func expand(text string, values map[string]string) string {
+	return variable.ReplaceAllStringFunc(text, func(reference string) string {
+		name := strings.Trim(reference, "${}")
+		if value, found := values[name]; found {
+			return value
+		}
+		return reference
+	})
+}
@@ -0,0 +1,151 @@
+package utility
+
+import (
+	"bytes"
+	"cloakroom/lib"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// pluginType is the type each plugin's settings are decoded into.
+var pluginType = reflect.TypeOf(lib.Plugin{})
+
+// Patch returns a manifest's content with one plugin set to the given settings, as produced by Encode,
+// or removed if plugin is nil. Only the plugin's own text changes: everything else, comments included,
+// is kept byte for byte. A plugin is added after the others, in the style they are written in, and a
+// replaced plugin keeps its key and the comments above it.
+//
+// An error is returned if the manifest can't be parsed, or lays out its plugins in a way that can't be edited
+// in place, e.g. a non-empty YAML flow mapping.
+func Patch(content []byte, format string, key string, plugin map[string]any) ([]byte, error) {
+	switch format {
+	case "yaml":
+		return patchYAML(content, key, plugin)
+	case "toml":
+		return patchTOML(content, key, plugin)
+	case "json":
+		return patchJSON(content, key, plugin)
+	case "hcl":
+		return patchHCL(content, key, plugin)
+	case "ini":
+		return patchINI(content, key, plugin)
+	}
+	return nil, fmt.Errorf("unknown format %q (expected one of %s)", format, strings.Join(ManifestFormats, ", "))
+}
+
+// splice replaces content[start:end] with replacement.
+func splice(content []byte, start int, end int, replacement []byte) []byte {
+	patched := make([]byte, 0, len(content)-(end-start)+len(replacement))
+	patched = append(patched, content[:start]...)
+	patched = append(patched, replacement...)
+	return append(patched, content[end:]...)
+}
+
+// lineStart returns the offset of the start of the line holding offset.
+func lineStart(content []byte, offset int) int {
+	return bytes.LastIndexByte(content[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset just past the end of the line holding offset, newline included.
+func lineEnd(content []byte, offset int) int {
+	if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+// indentation returns the width of the whitespace a line starts with.
+func indentation(line []byte) int {
+	return len(line) - len(bytes.TrimLeft(line, " \t"))
+}
+
+// commented reports whether a line is a comment, starting with one of markers after any indentation.
+func commented(line []byte, markers string) bool {
+	trimmed := bytes.TrimSpace(line)
+	return len(trimmed) > 0 && strings.IndexByte(markers, trimmed[0]) >= 0
+}
+
+// attached moves a line start back over the comment lines directly above it, which describe what follows them.
+func attached(content []byte, start int, markers string) int {
+	for start > 0 {
+		previous := lineStart(content, start-1)
+		if !commented(content[previous:start], markers) {
+			break
+		}
+		start = previous
+	}
+	return start
+}
+
+// trimmed moves the end of the lines [start, end) back over trailing blank lines, and trailing comment lines
+// indented no deeper than indent, which describe what follows rather than what precedes them.
+// The first line is always kept.
+func trimmed(content []byte, start int, end int, markers string, indent int) int {
+	first := lineEnd(content, start)
+	for end > first {
+		previous := lineStart(content, end-1)
+		line := content[previous:end]
+		if len(bytes.TrimSpace(line)) > 0 && !(commented(line, markers) && indentation(line) <= indent) {
+			break
+		}
+		end = previous
+	}
+	return end
+}
+
+// removeLines deletes the lines [start, end), and a blank line left doubled by their removal, or left at the end.
+func removeLines(content []byte, start int, end int) []byte {
+	patched := splice(content, start, end, nil)
+	if start == len(patched) {
+		for start > 0 && len(bytes.TrimSpace(patched[lineStart(patched, start-1):start])) == 0 {
+			start = lineStart(patched, start-1)
+		}
+		return patched[:start]
+	}
+	blankBefore := start == 0 || len(bytes.TrimSpace(patched[lineStart(patched, start-1):start])) == 0
+	if start < len(patched) && blankBefore {
+		next := lineEnd(patched, start)
+		if len(bytes.TrimSpace(patched[start:next])) == 0 {
+			patched = splice(patched, start, next, nil)
+		}
+	}
+	return patched
+}
+
+// blanks moves an offset at the start of a line past the blank lines that follow it.
+func blanks(content []byte, offset int) int {
+	for offset < len(content) {
+		next := lineEnd(content, offset)
+		if len(bytes.TrimSpace(content[offset:next])) > 0 {
+			break
+		}
+		offset = next
+	}
+	return offset
+}
+
+// separated reports whether the line before the one starting at start is blank.
+func separated(content []byte, start int) bool {
+	return start > 0 && len(bytes.TrimSpace(content[lineStart(content, start-1):start])) == 0
+}
+
+// appended adds lines at the end of content, starting them on a new line.
+func appended(content []byte, lines []byte) []byte {
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content, '\n')
+	}
+	return append(content, lines...)
+}
+
+// indented prefixes every non-empty line of text with indent.
+func indented(text []byte, indent string) []byte {
+	var buffer bytes.Buffer
+	for _, line := range bytes.SplitAfter(text, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			buffer.WriteString(indent)
+		}
+		buffer.Write(line)
+	}
+	return buffer.Bytes()
+}
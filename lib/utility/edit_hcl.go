@@ -0,0 +1,159 @@
+package utility
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	hclparser "github.com/hashicorp/hcl/hcl/parser"
+	"github.com/hashicorp/hcl/hcl/token"
+)
+
+// hclKeyText returns the value of an HCL key, unquoting it if needed.
+func hclKeyText(key *ast.ObjectKey) string {
+	if key.Token.Type == token.STRING {
+		if text, err := strconv.Unquote(key.Token.Text); err == nil {
+			return text
+		}
+	}
+	return key.Token.Text
+}
+
+// hclEnd returns the offset just past the end of an HCL value.
+func hclEnd(node ast.Node) int {
+	switch node := node.(type) {
+	case *ast.ObjectType:
+		return node.Rbrace.Offset + 1
+	case *ast.ListType:
+		return node.Rbrack.Offset + 1
+	case *ast.LiteralType:
+		return node.Token.Pos.Offset + len(node.Token.Text)
+	}
+	return node.Pos().Offset
+}
+
+// patchHCL edits the block of a plugin, either a `plugins "owner/repo" {` block or an entry of a plugins block.
+// A new plugin follows the others in the same style, or is added as a `plugins "owner/repo" {` block.
+func patchHCL(content []byte, key string, plugin map[string]any) ([]byte, error) {
+	file, err := hclparser.Parse(content)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("the manifest is not an HCL body")
+	}
+
+	// matches lists the plugin's items, siblings the items of other plugins, and container the plugins block
+	var matches, siblings []*ast.ObjectItem
+	var container *ast.ObjectType
+	for _, item := range list.Items {
+		if !strings.EqualFold(hclKeyText(item.Keys[0]), "plugins") {
+			continue
+		}
+		if len(item.Keys) == 2 {
+			if strings.EqualFold(hclKeyText(item.Keys[1]), key) {
+				matches = append(matches, item)
+			} else {
+				siblings = append(siblings, item)
+			}
+			continue
+		}
+		if object, ok := item.Val.(*ast.ObjectType); ok && len(item.Keys) == 1 {
+			container = object
+			for _, entry := range object.List.Items {
+				if len(entry.Keys) == 1 && strings.EqualFold(hclKeyText(entry.Keys[0]), key) {
+					matches = append(matches, entry)
+				}
+			}
+		}
+	}
+
+	indentOf := func(offset int) string {
+		start := lineStart(content, offset)
+		return string(content[start : start+indentation(content[start:offset])])
+	}
+	block := func(indent string) []byte {
+		var buffer bytes.Buffer
+		buffer.WriteString("{\n")
+		hclBody(&buffer, plugin, pluginType, indent+"  ")
+		buffer.WriteString(indent + "}")
+		return buffer.Bytes()
+	}
+
+	if len(matches) > 0 {
+		// The plugin is rewritten where it first appears; any later items for it are removed
+		patched := content
+		for n := len(matches) - 1; n >= 0; n-- {
+			item := matches[n]
+			object, ok := item.Val.(*ast.ObjectType)
+			if plugin != nil && n == 0 {
+				if !ok {
+					return nil, fmt.Errorf("plugin %s is not a block", key)
+				}
+				indent := indentOf(item.Pos().Offset)
+				patched = splice(patched, object.Lbrace.Offset, object.Rbrace.Offset+1, block(indent))
+				continue
+			}
+
+			start := item.Pos().Offset
+			if item.LeadComment != nil {
+				start = item.LeadComment.Pos().Offset
+			}
+			end := lineEnd(patched, hclEnd(item.Val))
+			if container != nil && container.List.Items[0] == item {
+				// The first entry of the plugins block takes the blank lines separating it from the next one along
+				end = blanks(patched, end)
+			}
+			patched = removeLines(patched, lineStart(patched, start), end)
+		}
+		return patched, nil
+	}
+	if plugin == nil {
+		return content, nil
+	}
+
+	label := strconv.Quote(key)
+	switch {
+	case len(siblings) > 0:
+		last := siblings[len(siblings)-1]
+		indent := indentOf(last.Pos().Offset)
+		entry := fmt.Sprintf("\n%s%s %s %s\n", indent, last.Keys[0].Token.Text, label, block(indent))
+		at := lineEnd(content, hclEnd(last.Val))
+		if at == len(content) && (at == 0 || content[at-1] != '\n') {
+			entry = "\n" + entry
+		}
+		return splice(content, at, at, []byte(entry)), nil
+
+	case container != nil:
+		outer := indentOf(container.Lbrace.Offset)
+		indent, assign := outer+"  ", ""
+		if items := container.List.Items; len(items) > 0 {
+			last := items[len(items)-1]
+			indent = indentOf(last.Pos().Offset)
+			if last.Assign.IsValid() {
+				assign = "= "
+			}
+			at := lineEnd(content, hclEnd(last.Val))
+			entry := fmt.Sprintf("%s%s %s%s\n", indent, label, assign, block(indent))
+			start := last.Pos().Offset
+			if last.LeadComment != nil {
+				start = last.LeadComment.Pos().Offset
+			}
+			if len(items) > 1 && separated(content, lineStart(content, start)) {
+				entry = "\n" + entry
+			}
+			return splice(content, at, at, []byte(entry)), nil
+		}
+		entry := fmt.Sprintf("{\n%s%s %s\n%s}", indent, label, block(indent), outer)
+		return splice(content, container.Lbrace.Offset, container.Rbrace.Offset+1, []byte(entry)), nil
+	}
+
+	entry := fmt.Sprintf("plugins %s %s\n", label, block(""))
+	if len(bytes.TrimSpace(content)) > 0 {
+		entry = "\n" + entry
+	}
+	return appended(content, []byte(entry)), nil
+}
@@ -0,0 +1,88 @@
+package utility
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// iniSection matches an INI section header, capturing its name.
+var iniSection = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+
+// patchINI edits the sections of a plugin, [plugins.owner/repo] and those nested in it. A new plugin's sections
+// follow those of the last plugin, or end the file.
+func patchINI(content []byte, key string, plugin map[string]any) ([]byte, error) {
+	type section struct {
+		name       string
+		start, end int
+	}
+	var sections []section
+	for offset := 0; offset < len(content); offset = lineEnd(content, offset) {
+		match := iniSection.FindSubmatch(content[offset:lineEnd(content, offset)])
+		if match == nil {
+			continue
+		}
+		if n := len(sections); n > 0 {
+			sections[n-1].end = offset
+		}
+		sections = append(sections, section{name: strings.TrimSpace(string(match[1])), start: offset, end: len(content)})
+	}
+
+	own := "plugins." + strings.ToLower(key)
+	belongs := func(name string) bool {
+		name = strings.ToLower(name)
+		return name == own || strings.HasPrefix(name, own+".")
+	}
+
+	var entry []byte
+	if plugin != nil {
+		var buffer bytes.Buffer
+		if err := iniSections(&buffer, map[string]any{"plugins": map[string]any{key: plugin}}); err != nil {
+			return nil, err
+		}
+		entry = append(bytes.TrimRight(buffer.Bytes(), "\n"), '\n')
+	}
+
+	// The plugin is rewritten where its first section is; any later sections of it are removed
+	first, last := -1, -1
+	for i := len(sections) - 1; i >= 0; i-- {
+		if belongs(sections[i].name) {
+			first = i
+		}
+	}
+	patched := content
+	for i := len(sections) - 1; i >= 0; i-- {
+		current := sections[i]
+		if last < 0 && strings.HasPrefix(strings.ToLower(current.name), "plugins.") {
+			last = i
+		}
+		if !belongs(current.name) {
+			continue
+		}
+		end := trimmed(patched, current.start, current.end, "#;", len(patched))
+		if i == first && plugin != nil {
+			if end == len(patched) && (end == 0 || patched[end-1] != '\n') {
+				entry = bytes.TrimSuffix(entry, []byte("\n"))
+			}
+			patched = splice(patched, current.start, end, entry)
+			continue
+		}
+		patched = removeLines(patched, attached(patched, current.start, "#;"), end)
+	}
+	if first >= 0 || plugin == nil {
+		return patched, nil
+	}
+
+	if last < 0 {
+		if len(bytes.TrimSpace(content)) > 0 {
+			entry = append([]byte("\n"), entry...)
+		}
+		return appended(content, entry), nil
+	}
+	at := trimmed(content, sections[last].start, sections[last].end, "#;", len(content))
+	entry = append([]byte("\n"), entry...)
+	if at == len(content) && (at == 0 || content[at-1] != '\n') {
+		entry = append([]byte("\n"), entry...)
+	}
+	return splice(content, at, at, entry), nil
+}
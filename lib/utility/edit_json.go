@@ -0,0 +1,192 @@
+package utility
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// jsonMember is a member of a JSON object: the offsets of its key, and of the start and end of its value.
+type jsonMember struct {
+	name            string
+	key, start, end int
+}
+
+// jsonObject returns the members of the object starting at offset, and the offsets of its braces.
+func jsonObject(content []byte, offset int) (members []jsonMember, lbrace int, rbrace int, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(content[offset:]))
+	at := func() int { return offset + int(decoder.InputOffset()) }
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, 0, 0, fmt.Errorf("expected an object at offset %d", offset)
+	}
+	lbrace = at() - 1
+
+	for decoder.More() {
+		previous := at()
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		member := jsonMember{name: token.(string), key: previous + bytes.IndexByte(content[previous:], '"')}
+
+		// The value starts after the colon following the key
+		member.start = at()
+		member.start += bytes.IndexByte(content[member.start:], ':') + 1
+		member.start += len(content[member.start:]) - len(bytes.TrimLeft(content[member.start:], " \t\r\n"))
+
+		for depth := 0; ; {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			switch token {
+			case json.Delim('{'), json.Delim('['):
+				depth++
+			case json.Delim('}'), json.Delim(']'):
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		member.end = at()
+		members = append(members, member)
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return nil, 0, 0, err
+	}
+	return members, lbrace, at() - 1, nil
+}
+
+// jsonFind returns the index of the member with a name, matched regardless of case, or -1.
+func jsonFind(members []jsonMember, name string) int {
+	for i, member := range members {
+		if strings.EqualFold(member.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// patchJSON edits the member of a plugin in the plugins object, indenting it like the members around it.
+func patchJSON(content []byte, key string, plugin map[string]any) ([]byte, error) {
+	start := len(content) - len(bytes.TrimLeft(content, " \t\r\n"))
+	root, rootLbrace, rootRbrace, err := jsonObject(content, start)
+	if err != nil {
+		return nil, err
+	}
+	unit := "  "
+	if len(root) > 0 {
+		unit = string(content[lineStart(content, root[0].key):root[0].key])
+	}
+
+	i := jsonFind(root, "plugins")
+	if i < 0 {
+		if plugin == nil {
+			return content, nil
+		}
+		entry, err := jsonValue(map[string]any{key: plugin}, reflect.MapOf(reflect.TypeOf(""), pluginType), unit, unit)
+		if err != nil {
+			return nil, err
+		}
+		return jsonInsert(content, root, rootLbrace, rootRbrace, "", unit, "plugins", entry), nil
+	}
+	if content[root[i].start] != '{' {
+		return nil, fmt.Errorf("plugins is not an object")
+	}
+
+	outer := string(content[lineStart(content, root[i].key):root[i].key])
+	plugins, lbrace, rbrace, err := jsonObject(content, root[i].start)
+	if err != nil {
+		return nil, err
+	}
+	indent := outer + unit
+	if len(plugins) > 0 {
+		indent = string(content[lineStart(content, plugins[0].key):plugins[0].key])
+	}
+
+	j := jsonFind(plugins, key)
+	switch {
+	case plugin == nil && j < 0:
+		return content, nil
+	case plugin == nil && len(plugins) == 1:
+		return splice(content, lbrace+1, rbrace, nil), nil
+	case plugin == nil && j == 0:
+		return splice(content, plugins[0].key, plugins[1].key, nil), nil
+	case plugin == nil:
+		return splice(content, plugins[j-1].end, plugins[j].end, nil), nil
+	}
+
+	entry, err := jsonValue(plugin, pluginType, indent, unit)
+	if err != nil {
+		return nil, err
+	}
+	if j >= 0 {
+		return splice(content, plugins[j].start, plugins[j].end, entry), nil
+	}
+	return jsonInsert(content, plugins, lbrace, rbrace, outer, indent, key, entry), nil
+}
+
+// jsonInsert adds a member after the last member of an object, or as its only member, indented by indent.
+// outer is the indentation of the line the object ends on.
+func jsonInsert(content []byte, members []jsonMember, lbrace int, rbrace int, outer string, indent string, name string, value []byte) []byte {
+	encoded, _ := json.Marshal(name)
+	member := append([]byte(indent+string(encoded)+": "), value...)
+	if len(members) == 0 {
+		return splice(content, lbrace+1, rbrace, append(append([]byte("\n"), member...), "\n"+outer...))
+	}
+	return splice(content, members[len(members)-1].end, members[len(members)-1].end, append([]byte(",\n"), member...))
+}
+
+// jsonValue renders a value as indented JSON, with the keys of objects in the order of the fields of type t.
+// indent is the indentation of the line the value starts on, and unit the indentation of each level.
+func jsonValue(value any, t reflect.Type, indent string, unit string) ([]byte, error) {
+	var buffer bytes.Buffer
+	switch value := value.(type) {
+	case map[string]any:
+		if len(value) == 0 {
+			return []byte("{}"), nil
+		}
+		buffer.WriteString("{")
+		for i, key := range ordered(value, t) {
+			nested, err := jsonValue(value[key], child(t, key), indent+unit, unit)
+			if err != nil {
+				return nil, err
+			}
+			encoded, _ := json.Marshal(key)
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			fmt.Fprintf(&buffer, "\n%s%s%s: %s", indent, unit, encoded, nested)
+		}
+		fmt.Fprintf(&buffer, "\n%s}", indent)
+	case []any:
+		if len(value) == 0 {
+			return []byte("[]"), nil
+		}
+		buffer.WriteString("[")
+		for i, item := range value {
+			nested, err := jsonValue(item, nil, indent+unit, unit)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				buffer.WriteString(",")
+			}
+			fmt.Fprintf(&buffer, "\n%s%s%s", indent, unit, nested)
+		}
+		fmt.Fprintf(&buffer, "\n%s]", indent)
+	default:
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(value); err != nil {
+			return nil, err
+		}
+		return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+	}
+	return buffer.Bytes(), nil
+}
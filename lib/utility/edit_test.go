@@ -0,0 +1,232 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// editSample is a manifest in one format, with the text that every edit of acme/theme must leave untouched.
+type editSample struct {
+	content string
+	kept    []string
+}
+
+var editSamples = map[string]editSample{
+	"yaml": {
+		content: `# Keycloak plugins
+version: "1.0"
+host: github.com
+plugins:
+  # Metrics, pinned for the dashboards
+  aerogear/keycloak-metrics-spi:
+    tag: "7.0.0" # keep in sync with grafana
+    artifact: keycloak-metrics-spi-7.0.0.jar
+  # Theme
+  acme/theme:
+    tag: v1.0.0
+    artifact: theme.jar
+# trailing comment
+`,
+		kept: []string{
+			"# Keycloak plugins\nversion: \"1.0\"\nhost: github.com\nplugins:\n",
+			"  # Metrics, pinned for the dashboards\n  aerogear/keycloak-metrics-spi:\n    tag: \"7.0.0\" # keep in sync with grafana\n    artifact: keycloak-metrics-spi-7.0.0.jar\n",
+			"# trailing comment\n",
+		},
+	},
+	"toml": {
+		content: `# Keycloak plugins
+version = "1.0"
+host = "github.com"
+
+# Metrics, pinned for the dashboards
+[plugins."aerogear/keycloak-metrics-spi"]
+tag = "7.0.0" # keep in sync with grafana
+artifact = "keycloak-metrics-spi-7.0.0.jar"
+
+# Theme
+[plugins."acme/theme"]
+tag = "v1.0.0"
+artifact = "theme.jar"
+`,
+		kept: []string{
+			"# Keycloak plugins\nversion = \"1.0\"\nhost = \"github.com\"\n",
+			"# Metrics, pinned for the dashboards\n[plugins.\"aerogear/keycloak-metrics-spi\"]\ntag = \"7.0.0\" # keep in sync with grafana\nartifact = \"keycloak-metrics-spi-7.0.0.jar\"\n",
+		},
+	},
+	"json": {
+		content: `{
+  "version": "1.0",
+  "host": "github.com",
+  "plugins": {
+    "aerogear/keycloak-metrics-spi": {
+      "tag": "7.0.0",
+      "artifact": "keycloak-metrics-spi-7.0.0.jar"
+    },
+    "acme/theme": {
+      "tag": "v1.0.0",
+      "artifact": "theme.jar"
+    }
+  }
+}
+`,
+		kept: []string{
+			"{\n  \"version\": \"1.0\",\n  \"host\": \"github.com\",\n  \"plugins\": {\n",
+			"    \"aerogear/keycloak-metrics-spi\": {\n      \"tag\": \"7.0.0\",\n      \"artifact\": \"keycloak-metrics-spi-7.0.0.jar\"\n    }",
+		},
+	},
+	"hcl": {
+		content: `# Keycloak plugins
+version = "1.0"
+host = "github.com"
+
+# Metrics, pinned for the dashboards
+plugins "aerogear/keycloak-metrics-spi" {
+  tag = "7.0.0" # keep in sync with grafana
+  artifact = "keycloak-metrics-spi-7.0.0.jar"
+}
+
+# Theme
+plugins "acme/theme" {
+  tag = "v1.0.0"
+  artifact = "theme.jar"
+}
+`,
+		kept: []string{
+			"# Keycloak plugins\nversion = \"1.0\"\nhost = \"github.com\"\n",
+			"# Metrics, pinned for the dashboards\nplugins \"aerogear/keycloak-metrics-spi\" {\n  tag = \"7.0.0\" # keep in sync with grafana\n  artifact = \"keycloak-metrics-spi-7.0.0.jar\"\n}\n",
+		},
+	},
+	"ini": {
+		content: `; Keycloak plugins
+version = 1.0
+host = github.com
+
+; Metrics, pinned for the dashboards
+[plugins.aerogear/keycloak-metrics-spi]
+tag = 7.0.0
+artifact = keycloak-metrics-spi-7.0.0.jar
+
+; Theme
+[plugins.acme/theme]
+tag = v1.0.0
+artifact = theme.jar
+`,
+		kept: []string{
+			"; Keycloak plugins\nversion = 1.0\nhost = github.com\n",
+			"; Metrics, pinned for the dashboards\n[plugins.aerogear/keycloak-metrics-spi]\ntag = 7.0.0\nartifact = keycloak-metrics-spi-7.0.0.jar\n",
+		},
+	},
+}
+
+// decodePlugins reads the plugins of a manifest as cloakroom would, from a file in the given format.
+func decodePlugins(t *testing.T, format string, content []byte) map[string]lib.Plugin {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "cloakroom."+format)
+	if err := os.WriteFile(file, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := Settings(file)
+	if err != nil {
+		t.Fatalf("%s: patched manifest doesn't parse: %v\n%s", format, err, content)
+	}
+	manifest := &lib.Manifest{}
+	if err := Decode(settings, manifest); err != nil {
+		t.Fatalf("%s: patched manifest doesn't decode: %v\n%s", format, err, content)
+	}
+	return manifest.Plugins
+}
+
+func TestPatch(t *testing.T) {
+	metrics := lib.Plugin{Tag: "7.0.0", Artifact: "keycloak-metrics-spi-7.0.0.jar"}
+	theme := lib.Plugin{Tag: "v1.0.0", Artifact: "theme.jar"}
+	added := lib.Plugin{Tag: "v1", Artifact: "new.jar", Mirrors: []string{"mirror.example.com"}, Keycloak: ">=21"}
+
+	edits := []struct {
+		name   string
+		key    string
+		plugin *lib.Plugin
+		want   map[string]lib.Plugin
+	}{
+		{"update", "acme/theme", &lib.Plugin{Tag: "v2.0.0", Artifact: "theme.jar"}, map[string]lib.Plugin{
+			"aerogear/keycloak-metrics-spi": metrics,
+			"acme/theme":                    {Tag: "v2.0.0", Artifact: "theme.jar"},
+		}},
+		{"add", "acme/new", &added, map[string]lib.Plugin{
+			"aerogear/keycloak-metrics-spi": metrics,
+			"acme/theme":                    theme,
+			"acme/new":                      added,
+		}},
+		{"remove", "acme/theme", nil, map[string]lib.Plugin{
+			"aerogear/keycloak-metrics-spi": metrics,
+		}},
+	}
+
+	for _, format := range ManifestFormats {
+		sample, found := editSamples[format]
+		if !found {
+			t.Errorf("no sample manifest for %s", format)
+			continue
+		}
+
+		for _, edit := range edits {
+			var settings map[string]any
+			if edit.plugin != nil {
+				settings, _ = Encode(*edit.plugin).(map[string]any)
+			}
+
+			patched, err := Patch([]byte(sample.content), format, edit.key, settings)
+			if err != nil {
+				t.Errorf("%s %s: %v", format, edit.name, err)
+				continue
+			}
+
+			for _, kept := range sample.kept {
+				if !strings.Contains(string(patched), kept) {
+					t.Errorf("%s %s: lost\n%s\nfrom\n%s", format, edit.name, kept, patched)
+				}
+			}
+			if got := decodePlugins(t, format, patched); !reflect.DeepEqual(got, edit.want) {
+				t.Errorf("%s %s: plugins = %+v, want %+v", format, edit.name, got, edit.want)
+			}
+		}
+	}
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	plugin, _ := Encode(lib.Plugin{Tag: "v1", Artifact: "new.jar", Mirrors: []string{"mirror.example.com"}}).(map[string]any)
+
+	for _, format := range ManifestFormats {
+		original := []byte(editSamples[format].content)
+
+		added, err := Patch(original, format, "acme/new", plugin)
+		if err != nil {
+			t.Errorf("%s: adding: %v", format, err)
+			continue
+		}
+		removed, err := Patch(added, format, "acme/new", nil)
+		if err != nil {
+			t.Errorf("%s: removing: %v", format, err)
+			continue
+		}
+		if string(removed) != string(original) {
+			t.Errorf("%s: adding then removing a plugin gave\n%s\nwant\n%s", format, removed, original)
+		}
+
+		// Removing a plugin that isn't there leaves the manifest as it is
+		unchanged, err := Patch(original, format, "acme/missing", nil)
+		if err != nil || string(unchanged) != string(original) {
+			t.Errorf("%s: removing a missing plugin gave %v\n%s", format, err, unchanged)
+		}
+	}
+}
+
+func TestPatchUnknownFormat(t *testing.T) {
+	if _, err := Patch([]byte("{}"), "xml", "acme/theme", nil); err == nil {
+		t.Error("Patch() with an unknown format: expected an error")
+	}
+}
@@ -0,0 +1,310 @@
+package utility
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// tomlExpression is a top-level TOML expression: a table header or a key/value pair.
+type tomlExpression struct {
+	table bool
+	// path is the full key of the table, or of the value within its table.
+	path []string
+	// keys holds the offsets of the parts of the expression's own key.
+	keys []unstable.Range
+	// inline reports whether the value is an inline table; empty whether it is an empty one.
+	inline, empty bool
+	// start is the offset of the line the expression starts on.
+	start int
+}
+
+// tomlExpressions lists the top-level expressions of a TOML document.
+func tomlExpressions(content []byte) ([]tomlExpression, error) {
+	var parser unstable.Parser
+	parser.Reset(content)
+
+	var expressions []tomlExpression
+	var table []string
+	for parser.NextExpression() {
+		node := parser.Expression()
+		expression := tomlExpression{table: node.Kind == unstable.Table || node.Kind == unstable.ArrayTable}
+
+		var key []string
+		for parts := node.Key(); parts.Next(); {
+			key = append(key, string(parts.Node().Data))
+			expression.keys = append(expression.keys, parts.Node().Raw)
+		}
+		expression.start = lineStart(content, int(expression.keys[0].Offset))
+
+		if expression.table {
+			table = key
+			expression.path = key
+		} else {
+			expression.path = append(append([]string{}, table...), key...)
+			value := node.Value()
+			expression.inline = value.Kind == unstable.InlineTable
+			expression.empty = expression.inline && value.Child() == nil
+		}
+		expressions = append(expressions, expression)
+	}
+	if err := parser.Error(); err != nil {
+		return nil, err
+	}
+	return expressions, nil
+}
+
+// plugin returns the key of the plugin an expression belongs to, or "" if it doesn't belong to one.
+func (expression tomlExpression) plugin() string {
+	if len(expression.path) < 2 || !strings.EqualFold(expression.path[0], "plugins") {
+		return ""
+	}
+	return expression.path[1]
+}
+
+// part returns the index of the part of the expression's own key naming its plugin.
+func (expression tomlExpression) part() int {
+	return 1 - (len(expression.path) - len(expression.keys))
+}
+
+// prefix returns the key text an expression uses for its plugin, up to and including the plugin's key,
+// e.g. `plugins."acme/theme"` for a table header, or `"acme/theme"` for an inline table under [plugins].
+func (expression tomlExpression) prefix(content []byte) string {
+	first, last := expression.keys[0], expression.keys[expression.part()]
+	return string(content[first.Offset : last.Offset+last.Length])
+}
+
+// lastKey returns the text of the part of the prefix naming the plugin, e.g. `"acme/theme"`.
+func (expression tomlExpression) lastKey(content []byte) string {
+	key := expression.keys[expression.part()]
+	return string(content[key.Offset : key.Offset+key.Length])
+}
+
+// tomlStyle is how a plugin is written in a TOML manifest.
+type tomlStyle int
+
+const (
+	// tomlTable writes a plugin as a table, [plugins."acme/theme"], with its own tables for nested objects.
+	tomlTable tomlStyle = iota
+	// tomlInline writes a plugin as an inline table under [plugins].
+	tomlInline
+	// tomlDotted writes each of a plugin's values with a dotted key, e.g. plugins."acme/theme".tag.
+	tomlDotted
+)
+
+// style returns how the plugin an expression belongs to is written, from the first of its expressions.
+func (expression tomlExpression) style() tomlStyle {
+	switch {
+	case expression.table:
+		return tomlTable
+	case expression.inline && expression.part() == len(expression.keys)-1:
+		return tomlInline
+	}
+	return tomlDotted
+}
+
+// patchTOML edits the expressions of a plugin, keeping the style it or, for a new plugin, the last plugin is
+// written in. Plugins are written as tables when the manifest has none.
+func patchTOML(content []byte, key string, plugin map[string]any) ([]byte, error) {
+	expressions, err := tomlExpressions(content)
+	if err != nil {
+		return nil, err
+	}
+	writer := newTOMLWriter(content)
+	// end returns where the lines of expressions [from, to) end, before the comments describing the next one
+	end := func(from int, to int) int {
+		next := len(content)
+		if to < len(expressions) {
+			next = expressions[to].start
+		}
+		return trimmed(content, expressions[from].start, next, "#", len(content))
+	}
+
+	// runs lists the consecutive expressions of the plugin, as [from, to) index ranges
+	var runs [][2]int
+	last := -1
+	for i, expression := range expressions {
+		name := expression.plugin()
+		if name == "" {
+			continue
+		}
+		last = i
+		if !strings.EqualFold(name, key) {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1][1] == i {
+			runs[n-1][1] = i + 1
+		} else {
+			runs = append(runs, [2]int{i, i + 1})
+		}
+	}
+
+	if len(runs) > 0 {
+		// The plugin is rewritten where it first appears; any later parts of it are removed
+		patched := content
+		for n := len(runs) - 1; n >= 0; n-- {
+			from, to := runs[n][0], runs[n][1]
+			start, stop := expressions[from].start, end(from, to)
+			if plugin != nil && n == 0 {
+				first := expressions[from]
+				entry := writer.entry(first.style(), first.prefix(content), plugin)
+				if stop == len(content) && (stop == 0 || content[stop-1] != '\n') {
+					entry = bytes.TrimSuffix(entry, []byte("\n"))
+				}
+				patched = splice(patched, start, stop, entry)
+				continue
+			}
+			patched = removeLines(patched, attached(patched, start, "#"), stop)
+		}
+		return patched, nil
+	}
+	if plugin == nil {
+		return content, nil
+	}
+
+	quoted := writer.key(key)
+	if last >= 0 {
+		// Follow the last plugin: find the expression it starts with, and insert after its last one
+		first := last
+		for first > 0 && strings.EqualFold(expressions[first-1].plugin(), expressions[last].plugin()) {
+			first--
+		}
+		style, prefix := expressions[first].style(), expressions[first].prefix(content)
+		prefix = prefix[:len(prefix)-len(expressions[first].lastKey(content))] + quoted
+
+		entry := writer.entry(style, prefix, plugin)
+		if style == tomlTable {
+			entry = append([]byte("\n"), entry...)
+		}
+		at := end(last, last+1)
+		if at == len(content) && (at == 0 || content[at-1] != '\n') {
+			entry = append([]byte("\n"), entry...)
+		}
+		return splice(content, at, at, entry), nil
+	}
+
+	entry := writer.entry(tomlTable, "plugins."+quoted, plugin)
+	for i, expression := range expressions {
+		if len(expression.path) != 1 || !strings.EqualFold(expression.path[0], "plugins") {
+			continue
+		}
+		switch {
+		case expression.table:
+			// Plugin tables follow the empty [plugins] table
+			at := end(i, i+1)
+			return splice(content, at, at, append([]byte("\n"), entry...)), nil
+		case expression.empty:
+			// plugins = {} is replaced by plugin tables, which must come after every top-level value
+			patched := removeLines(content, expression.start, end(i, i+1))
+			return appended(patched, append([]byte("\n"), entry...)), nil
+		}
+		return nil, fmt.Errorf("plugins is not a table")
+	}
+	if len(bytes.TrimSpace(content)) > 0 {
+		entry = append([]byte("\n"), entry...)
+	}
+	return appended(content, entry), nil
+}
+
+// bareKey matches the TOML keys that need no quotes.
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlWriter renders plugins in TOML, quoting strings like the manifest does: as basic strings ("...")
+// if double is set, otherwise as literal strings ('...') like the TOML encoder.
+type tomlWriter struct {
+	double bool
+}
+
+// newTOMLWriter returns a writer that quotes strings the way most values in content are quoted.
+func newTOMLWriter(content []byte) tomlWriter {
+	return tomlWriter{double: bytes.Count(content, []byte(`= "`)) > bytes.Count(content, []byte(`= '`))}
+}
+
+// quote renders a string, as a literal string unless double is set or it can't be one.
+func (writer tomlWriter) quote(text string) string {
+	if writer.double || strings.ContainsAny(text, "'\n\r\t") {
+		encoded, _ := json.Marshal(text) // JSON escapes are valid in TOML basic strings
+		return string(encoded)
+	}
+	return "'" + text + "'"
+}
+
+// key renders a key, quoted when it isn't bare.
+func (writer tomlWriter) key(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return writer.quote(key)
+}
+
+// entry renders a plugin in a style, its keys starting with prefix.
+func (writer tomlWriter) entry(style tomlStyle, prefix string, plugin map[string]any) []byte {
+	var buffer bytes.Buffer
+	switch style {
+	case tomlTable:
+		writer.tables(&buffer, prefix, plugin, pluginType)
+	case tomlInline:
+		fmt.Fprintf(&buffer, "%s = %s\n", prefix, writer.value(plugin, pluginType))
+	case tomlDotted:
+		writer.dotted(&buffer, prefix, plugin, pluginType)
+	}
+	return buffer.Bytes()
+}
+
+// tables writes an object as a table named header, followed by a table for each nested object.
+func (writer tomlWriter) tables(buffer *bytes.Buffer, header string, values map[string]any, t reflect.Type) {
+	fmt.Fprintf(buffer, "[%s]\n", header)
+	var nested []string
+	for _, key := range ordered(values, t) {
+		if _, ok := values[key].(map[string]any); ok {
+			nested = append(nested, key)
+			continue
+		}
+		fmt.Fprintf(buffer, "%s = %s\n", writer.key(key), writer.value(values[key], child(t, key)))
+	}
+
+	for _, key := range nested {
+		buffer.WriteString("\n")
+		writer.tables(buffer, header+"."+writer.key(key), values[key].(map[string]any), child(t, key))
+	}
+}
+
+// dotted writes each value of an object, nested ones included, with a dotted key starting with prefix.
+func (writer tomlWriter) dotted(buffer *bytes.Buffer, prefix string, values map[string]any, t reflect.Type) {
+	for _, key := range ordered(values, t) {
+		if object, ok := values[key].(map[string]any); ok {
+			writer.dotted(buffer, prefix+"."+writer.key(key), object, child(t, key))
+			continue
+		}
+		fmt.Fprintf(buffer, "%s.%s = %s\n", prefix, writer.key(key), writer.value(values[key], child(t, key)))
+	}
+}
+
+// value renders a value, with objects as inline tables.
+func (writer tomlWriter) value(value any, t reflect.Type) string {
+	switch value := value.(type) {
+	case string:
+		return writer.quote(value)
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			items = append(items, writer.value(item, nil))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		if len(value) == 0 {
+			return "{}"
+		}
+		pairs := make([]string, 0, len(value))
+		for _, key := range ordered(value, t) {
+			pairs = append(pairs, writer.key(key)+" = "+writer.value(value[key], child(t, key)))
+		}
+		return "{ " + strings.Join(pairs, ", ") + " }"
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,208 @@
+package utility
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlOffset returns the offset of a 1-based line and column, as reported by the YAML parser.
+func yamlOffset(content []byte, line int, column int) int {
+	offset := 0
+	for ; line > 1; line-- {
+		offset = lineEnd(content, offset)
+	}
+	for ; column > 1 && offset < len(content); column-- {
+		_, size := utf8.DecodeRune(content[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// yamlMember returns the key and value of an entry of a YAML mapping, matched regardless of case, and the key
+// of the entry after it. Keys that aren't found are nil.
+func yamlMember(mapping *yaml.Node, name string) (key *yaml.Node, value *yaml.Node, next *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, name) {
+			if i+2 < len(mapping.Content) {
+				next = mapping.Content[i+2]
+			}
+			return mapping.Content[i], mapping.Content[i+1], next
+		}
+	}
+	return nil, nil, nil
+}
+
+// patchYAML edits the entry of a plugin in the block mapping under plugins. An empty flow mapping, e.g.
+// `plugins: {}`, becomes a block mapping.
+func patchYAML(content []byte, key string, plugin map[string]any) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, err
+	}
+
+	var root *yaml.Node
+	if len(document.Content) > 0 {
+		root = document.Content[0]
+	}
+	if root != nil && (root.Kind != yaml.MappingNode || root.Style&yaml.FlowStyle != 0) {
+		return nil, fmt.Errorf("the manifest is not a block mapping")
+	}
+
+	var pluginsKey, plugins, after *yaml.Node
+	if root != nil {
+		pluginsKey, plugins, after = yamlMember(root, "plugins")
+	}
+	// end is where the plugins mapping ends: the start of the next top-level key, or the end of the file
+	end := len(content)
+	if after != nil {
+		end = lineStart(content, yamlOffset(content, after.Line, after.Column))
+	}
+
+	switch {
+	case plugins == nil:
+		if plugin == nil {
+			return content, nil
+		}
+		entry, err := yamlEntry(&yaml.Node{Kind: yaml.ScalarNode, Value: key}, plugin, 2, 2, false)
+		if err != nil {
+			return nil, err
+		}
+		return appended(content, append([]byte("plugins:\n"), entry...)), nil
+
+	case plugins.Kind == yaml.ScalarNode && plugins.Tag == "!!null" && plugins.Value == "",
+		plugins.Kind == yaml.MappingNode && plugins.Style&yaml.FlowStyle != 0 && len(plugins.Content) == 0:
+		if plugin == nil {
+			return content, nil
+		}
+		entry, err := yamlEntry(&yaml.Node{Kind: yaml.ScalarNode, Value: key}, plugin, pluginsKey.Column+1, 2, false)
+		if err != nil {
+			return nil, err
+		}
+
+		// Drop the {} and the spaces before it, keeping any comment on the line
+		patched := content
+		if plugins.Kind == yaml.MappingNode {
+			offset := yamlOffset(content, plugins.Line, plugins.Column)
+			if !bytes.HasPrefix(content[offset:], []byte("{}")) {
+				return nil, fmt.Errorf("plugins is an empty flow mapping that spans several lines")
+			}
+			from := offset
+			for from > 0 && (content[from-1] == ' ' || content[from-1] == '\t') {
+				from--
+			}
+			patched = splice(content, from, offset+2, nil)
+		}
+		line := yamlOffset(patched, pluginsKey.Line, 1)
+		next := lineEnd(patched, line)
+		if next == len(patched) && (next == 0 || patched[next-1] != '\n') {
+			entry = append([]byte("\n"), entry...)
+		}
+		return splice(patched, next, next, entry), nil
+
+	case plugins.Kind != yaml.MappingNode || plugins.Style&yaml.FlowStyle != 0:
+		return nil, fmt.Errorf("plugins is not a block mapping")
+	}
+
+	entryKey, entryValue, next := yamlMember(plugins, key)
+	last := plugins.Content[len(plugins.Content)-2]
+	indent, step := last.Column-1, last.Column-pluginsKey.Column
+	if step <= 0 {
+		step = 2
+	}
+
+	if entryKey == nil {
+		if plugin == nil {
+			return content, nil
+		}
+		flow := plugins.Content[len(plugins.Content)-1].Style&yaml.FlowStyle != 0
+		entry, err := yamlEntry(&yaml.Node{Kind: yaml.ScalarNode, Value: key}, plugin, indent, step, flow)
+		if err != nil {
+			return nil, err
+		}
+		start := lineStart(content, yamlOffset(content, last.Line, last.Column))
+		at := trimmed(content, start, end, "#", indent)
+		if len(plugins.Content) > 2 && separated(content, attached(content, start, "#")) {
+			entry = append([]byte("\n"), entry...)
+		}
+		if at == len(content) && (at == 0 || content[at-1] != '\n') {
+			entry = append([]byte("\n"), entry...)
+		}
+		return splice(content, at, at, entry), nil
+	}
+
+	keyOffset := yamlOffset(content, entryKey.Line, entryKey.Column)
+	start := lineStart(content, keyOffset)
+	if len(bytes.TrimSpace(content[start:keyOffset])) > 0 {
+		return nil, fmt.Errorf("plugin %s doesn't start its own line", key)
+	}
+	if next != nil {
+		end = lineStart(content, yamlOffset(content, next.Line, next.Column))
+	}
+	end = trimmed(content, start, end, "#", indent)
+
+	if plugin == nil {
+		if entryKey == plugins.Content[0] {
+			// The first entry takes the blank lines separating it from the next one along
+			end = blanks(content, end)
+		}
+		return removeLines(content, attached(content, start, "#"), end), nil
+	}
+
+	// The key is kept as written, quotes and line comment included
+	replacement := &yaml.Node{Kind: yaml.ScalarNode, Value: entryKey.Value, Style: entryKey.Style, LineComment: entryKey.LineComment}
+	entry, err := yamlEntry(replacement, plugin, indent, step, entryValue.Style&yaml.FlowStyle != 0)
+	if err != nil {
+		return nil, err
+	}
+	if end == len(content) && (end == 0 || content[end-1] != '\n') {
+		entry = bytes.TrimSuffix(entry, []byte("\n"))
+	}
+	return splice(content, start, end, entry), nil
+}
+
+// yamlEntry renders a plugin entry indented by indent spaces, nesting by step spaces, in flow style if flow is set.
+func yamlEntry(key *yaml.Node, plugin map[string]any, indent int, step int, flow bool) ([]byte, error) {
+	value, err := yamlNode(plugin, pluginType)
+	if err != nil {
+		return nil, err
+	}
+	if flow {
+		value.Style = yaml.FlowStyle
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(step)
+	if err := encoder.Encode(&yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{key, value}}); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return indented(buffer.Bytes(), strings.Repeat(" ", indent)), nil
+}
+
+// yamlNode builds the YAML node of a value decoded into type t, with the keys of objects in the order of
+// the fields of t.
+func yamlNode(value any, t reflect.Type) (*yaml.Node, error) {
+	object, ok := value.(map[string]any)
+	if !ok {
+		node := &yaml.Node{}
+		return node, node.Encode(value)
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range ordered(object, t) {
+		nested, err := yamlNode(object[key], child(t, key))
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, nested)
+	}
+	return node, nil
+}
@@ -0,0 +1,67 @@
+package utility
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Encode converts manifest values into plain maps keyed by their mapstructure names, omitting unset fields,
+// so that they are written back to the manifest exactly as they would be read.
+func Encode(value any) any {
+	encoded, _ := encode(reflect.ValueOf(value))
+	return encoded
+}
+
+// encode returns the plain representation of value, and false if it is unset and should be omitted.
+func encode(value reflect.Value) (any, bool) {
+	if !value.IsValid() {
+		return nil, false
+	}
+
+	if duration, ok := value.Interface().(time.Duration); ok {
+		return duration.String(), true
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil, false
+		}
+		return encode(value.Elem())
+	case reflect.Struct:
+		result := map[string]any{}
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			if encoded, ok := encode(value.Field(i)); ok {
+				result[name] = encoded
+			}
+		}
+		return result, len(result) > 0
+	case reflect.Map:
+		result := make(map[string]any, value.Len())
+		iterator := value.MapRange()
+		for iterator.Next() {
+			encoded, ok := encode(iterator.Value())
+			if !ok {
+				encoded = map[string]any{}
+			}
+			result[fmt.Sprint(iterator.Key().Interface())] = encoded
+		}
+		return result, len(result) > 0
+	case reflect.Slice, reflect.Array:
+		result := make([]any, 0, value.Len())
+		for i := 0; i < value.Len(); i++ {
+			encoded, _ := encode(value.Index(i))
+			result = append(result, encoded)
+		}
+		return result, len(result) > 0
+	default:
+		return value.Interface(), !value.IsZero()
+	}
+}
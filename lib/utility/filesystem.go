@@ -1,7 +1,9 @@
 package utility
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -43,6 +45,28 @@ func Clean(dir string) error {
 	return nil
 }
 
+// Snapshot returns the SHA3-512 of every regular file under dir, keyed by its path relative to dir.
+// A directory that doesn't exist has no files.
+func Snapshot(dir string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == dir {
+			return filepath.SkipDir
+		}
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[relative], err = Checksum(path)
+		return err
+	})
+	return files, err
+}
+
 // Remove removes a single file from the filesystem.
 func Remove(path string) error {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
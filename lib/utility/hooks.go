@@ -0,0 +1,82 @@
+package utility
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// Hook runs each command for a lifecycle stage in order through the system shell, stopping at the first failure.
+// The variables in env are added to the current environment, along with CLOAKROOM_HOOK naming the stage.
+func Hook(ctx context.Context, stage string, commands []string, env map[string]string) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	environment := append(os.Environ(), "CLOAKROOM_HOOK="+stage)
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		environment = append(environment, name+"="+env[name])
+	}
+
+	for _, command := range commands {
+		fmt.Printf("[HOOK] %s: %s\n", stage, command)
+		if err := Shell(ctx, command, environment); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", stage, command, err)
+		}
+	}
+
+	return nil
+}
+
+// Shell executes a command line through the system shell, streaming its output.
+func Shell(ctx context.Context, command string, environment []string) error {
+	if runtime.GOOS == "windows" {
+		return Run(ctx, environment, "cmd", "/C", command)
+	}
+	return Run(ctx, environment, "sh", "-c", command)
+}
+
+// Run executes a program with the given environment, streaming its output.
+func Run(ctx context.Context, environment []string, name string, args ...string) error {
+	process := exec.CommandContext(ctx, name, args...)
+	process.Env = environment
+	process.Stdout = os.Stdout
+	process.Stderr = os.Stderr
+	return process.Run()
+}
+
+// KcSh locates Keycloak's kc.sh (or kc.bat on Windows), either under home or relative to the wardrobe,
+// which is normally the providers directory of the Keycloak installation.
+func KcSh(home string, wardrobe string) (string, error) {
+	script := "kc.sh"
+	if runtime.GOOS == "windows" {
+		script = "kc.bat"
+	}
+
+	var candidates []string
+	if home != "" {
+		candidates = append(candidates, filepath.Join(home, "bin", script))
+	}
+	if wardrobe != "" {
+		if absolute, err := filepath.Abs(wardrobe); err == nil {
+			candidates = append(candidates, filepath.Join(filepath.Dir(absolute), "bin", script))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not find %s (set KC_HOME or use a wardrobe inside the Keycloak installation)", script)
+}
@@ -0,0 +1,177 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// uninterpolated lists the manifest fields left as written: hooks are expanded by the shell that runs them,
+// with the variables cloakroom sets, includes are resolved before the manifest is decoded, and a profile's values
+// are interpolated once it is applied.
+var uninterpolated = map[string]bool{"hooks": true, "include": true, "profiles": true}
+
+// variableName matches the name at the start of a ${...} reference.
+var variableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// Interpolate expands environment variable references in the manifest's values, in place:
+//
+//	${VAR}            the value of VAR, empty if it is unset
+//	${VAR:-default}   default if VAR is unset or empty; ${VAR-default} only if it is unset
+//	${VAR:?message}   an error if VAR is unset or empty; ${VAR?message} only if it is unset
+//	$$                a literal $
+//
+// Defaults may themselves hold references. Expanded values are never expanded again, and keys, such as plugin
+// names, are left as they are. A value that can't be expanded is left as written and reported as a problem.
+func Interpolate(manifest *lib.Manifest) []Problem {
+	return interpolate(reflect.ValueOf(manifest).Elem(), Location{})
+}
+
+// interpolate expands the strings held by an addressable value.
+func interpolate(value reflect.Value, location Location) []Problem {
+	var problems []Problem
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			break
+		}
+		if !value.CanSet() {
+			return interpolate(value.Elem(), location)
+		}
+		// Values are expanded in a copy, since the value pointed to may be shared, e.g. by a copy of the manifest
+		expanded := reflect.New(value.Type().Elem())
+		expanded.Elem().Set(value.Elem())
+		problems = interpolate(expanded.Elem(), location)
+		value.Set(expanded)
+	case reflect.String:
+		expanded, err := substitute(value.String())
+		if err != nil {
+			return []Problem{{Path: location, Message: err.Error()}}
+		}
+		value.SetString(expanded)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			nested := append(append(Location{}, location...), fmt.Sprintf("[%d]", i))
+			problems = append(problems, interpolate(value.Index(i), nested)...)
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			// Map entries aren't addressable, so each is expanded in a copy and stored back
+			entry := reflect.New(value.Type().Elem()).Elem()
+			entry.Set(value.MapIndex(key))
+			nested := append(append(Location{}, location...), key.String())
+			problems = append(problems, interpolate(entry, nested)...)
+			value.SetMapIndex(key, entry)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			key := value.Type().Field(i).Tag.Get("mapstructure")
+			if key == "" || uninterpolated[key] {
+				continue
+			}
+			nested := append(append(Location{}, location...), key)
+			problems = append(problems, interpolate(value.Field(i), nested)...)
+		}
+	}
+	return problems
+}
+
+// substitute replaces the variable references in text.
+func substitute(text string) (string, error) {
+	var builder strings.Builder
+	for {
+		start := strings.IndexByte(text, '$')
+		if start < 0 || start == len(text)-1 {
+			builder.WriteString(text)
+			return builder.String(), nil
+		}
+		builder.WriteString(text[:start])
+
+		switch text[start+1] {
+		case '$':
+			builder.WriteByte('$')
+			text = text[start+2:]
+		case '{':
+			end := closing(text[start+2:])
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference %q", text[start:])
+			}
+			value, err := reference(text[start+2 : start+2+end])
+			if err != nil {
+				return "", err
+			}
+			builder.WriteString(value)
+			text = text[start+2+end+1:]
+		default:
+			builder.WriteByte('$')
+			text = text[start+1:]
+		}
+	}
+}
+
+// closing returns the index of the brace closing a reference, skipping those of the references nested in it,
+// or -1 if there is none. text starts right after the opening "${".
+func closing(text string) int {
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '$' && i+1 < len(text) && (text[i+1] == '{' || text[i+1] == '$'):
+			if text[i+1] == '{' {
+				depth++
+			}
+			i++
+		case text[i] == '}' && depth == 0:
+			return i
+		case text[i] == '}':
+			depth--
+		}
+	}
+	return -1
+}
+
+// reference resolves the expression between the braces of a reference, e.g. VAR:-default.
+func reference(expression string) (string, error) {
+	name := variableName.FindString(expression)
+	if name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", expression)
+	}
+	value, set := os.LookupEnv(name)
+	operator := expression[len(name):]
+
+	switch {
+	case operator == "":
+		return value, nil
+	case strings.HasPrefix(operator, ":-"):
+		if value == "" {
+			return substitute(operator[2:])
+		}
+		return value, nil
+	case strings.HasPrefix(operator, "-"):
+		if !set {
+			return substitute(operator[1:])
+		}
+		return value, nil
+	case strings.HasPrefix(operator, ":?"):
+		if value == "" {
+			return "", missing(name, operator[2:])
+		}
+		return value, nil
+	case strings.HasPrefix(operator, "?"):
+		if !set {
+			return "", missing(name, operator[1:])
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid variable reference ${%s}", expression)
+}
+
+// missing returns the error for a required variable that isn't set, with the manifest's message if it has one.
+func missing(name string, message string) error {
+	if message == "" {
+		return fmt.Errorf("%s is required", name)
+	}
+	return fmt.Errorf("%s: %s", name, message)
+}
@@ -0,0 +1,118 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	t.Setenv("CLOAKROOM_TEST_SET", "21.1.0")
+	t.Setenv("CLOAKROOM_TEST_EMPTY", "")
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${CLOAKROOM_TEST_SET}", "21.1.0"},
+		{"v${CLOAKROOM_TEST_SET}-final", "v21.1.0-final"},
+		{"${CLOAKROOM_TEST_UNSET}", ""},
+		{"${CLOAKROOM_TEST_UNSET:-7.0.0}", "7.0.0"},
+		{"${CLOAKROOM_TEST_EMPTY:-7.0.0}", "7.0.0"},
+		{"${CLOAKROOM_TEST_EMPTY-7.0.0}", ""},
+		{"${CLOAKROOM_TEST_UNSET-7.0.0}", "7.0.0"},
+		{"${CLOAKROOM_TEST_SET:-7.0.0}", "21.1.0"},
+		{"${CLOAKROOM_TEST_UNSET:-${CLOAKROOM_TEST_SET}}", "21.1.0"},
+		{"${CLOAKROOM_TEST_UNSET:-${CLOAKROOM_TEST_EMPTY:-nested}}", "nested"},
+		{"${CLOAKROOM_TEST_SET:?required}", "21.1.0"},
+		{"${CLOAKROOM_TEST_EMPTY?required}", ""},
+		{"$$HOME", "$HOME"},
+		{"cost: $5", "cost: $5"},
+		{"trailing $", "trailing $"},
+		{"${CLOAKROOM_TEST_UNSET:-$${literal}}", "${literal}"},
+	}
+
+	for _, test := range tests {
+		got, err := substitute(test.text)
+		if err != nil {
+			t.Errorf("substitute(%q): unexpected error %v", test.text, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("substitute(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestSubstituteErrors(t *testing.T) {
+	t.Setenv("CLOAKROOM_TEST_EMPTY", "")
+
+	tests := []struct {
+		text    string
+		message string
+	}{
+		{"${CLOAKROOM_TEST_UNSET:?set the version}", "CLOAKROOM_TEST_UNSET: set the version"},
+		{"${CLOAKROOM_TEST_EMPTY:?}", "CLOAKROOM_TEST_EMPTY is required"},
+		{"${CLOAKROOM_TEST_UNSET?}", "CLOAKROOM_TEST_UNSET is required"},
+		{"${CLOAKROOM_TEST_UNSET", "unterminated variable reference"},
+		{"${1INVALID}", "invalid variable reference"},
+		{"${CLOAKROOM_TEST_UNSET:+alternative}", "invalid variable reference"},
+	}
+
+	for _, test := range tests {
+		_, err := substitute(test.text)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("substitute(%q) = %v, want an error containing %q", test.text, err, test.message)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("CLOAKROOM_TEST_VERSION", "7.1.0")
+
+	hash := "${CLOAKROOM_TEST_HASH:-" + strings.Repeat("a", 128) + "}"
+	manifest := &lib.Manifest{
+		Host:    "${CLOAKROOM_TEST_HOST:-github.com}",
+		Mirrors: []string{"https://${CLOAKROOM_TEST_MIRROR:-mirror.example.com}"},
+		Plugins: map[string]lib.Plugin{
+			"acme/${KEY}": {
+				Tag:      "${CLOAKROOM_TEST_VERSION}",
+				Artifact: "metrics-${CLOAKROOM_TEST_VERSION}.jar",
+				Hash:     &hash,
+				Hooks:    lib.PluginHooks{PostInstall: []string{"echo ${CLOAKROOM_DESTINATION}"}},
+			},
+			"acme/theme": {Tag: "${CLOAKROOM_TEST_UNSET:?set the theme}", Artifact: "theme.jar"},
+		},
+		Profiles: map[string]lib.Profile{"dev": {Host: "${CLOAKROOM_TEST_DEV_HOST}"}},
+	}
+
+	problems := Interpolate(manifest)
+	if len(problems) != 1 || problems[0].Path.String() != `plugins."acme/theme".tag` {
+		t.Errorf("Interpolate() = %v, want one problem at plugins.\"acme/theme\".tag", problems)
+	}
+
+	plugin := manifest.Plugins["acme/${KEY}"]
+	checks := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"host", manifest.Host, "github.com"},
+		{"mirror", manifest.Mirrors[0], "https://mirror.example.com"},
+		{"tag", plugin.Tag, "7.1.0"},
+		{"artifact", plugin.Artifact, "metrics-7.1.0.jar"},
+		{"hash", *plugin.Hash, strings.Repeat("a", 128)},
+		{"hook", plugin.Hooks.PostInstall[0], "echo ${CLOAKROOM_DESTINATION}"},
+		{"failed value", manifest.Plugins["acme/theme"].Tag, "${CLOAKROOM_TEST_UNSET:?set the theme}"},
+		{"profile", manifest.Profiles["dev"].Host, "${CLOAKROOM_TEST_DEV_HOST}"},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s = %q, want %q", check.name, check.got, check.want)
+		}
+	}
+	if hash != "${CLOAKROOM_TEST_HASH:-"+strings.Repeat("a", 128)+"}" {
+		t.Errorf("Interpolate() changed the hash it points to")
+	}
+}
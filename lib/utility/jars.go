@@ -0,0 +1,170 @@
+package utility
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Jar describes what a plugin JAR declares about itself.
+type Jar struct {
+	// Title and Version are read from META-INF/MANIFEST.MF.
+	Title   string
+	Version string
+	// Attributes holds every main attribute of META-INF/MANIFEST.MF.
+	Attributes map[string]string
+	// Services maps each SPI interface in META-INF/services to the classes registered for it.
+	Services map[string][]string
+	// Classes lists the fully-qualified name of every class in the JAR.
+	Classes []string
+	// Poms describes each Maven project bundled under META-INF/maven, see Pom.
+	Poms []Pom
+	// Licenses lists the licenses found in META-INF/LICENSE files and the Bundle-License attribute, see License.
+	Licenses []string
+}
+
+// Inspect opens a JAR and reads its manifest and service registrations.
+func Inspect(file string) (*Jar, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+	defer func(archive *zip.ReadCloser) {
+		_ = archive.Close()
+	}(archive)
+
+	jar := &Jar{Attributes: map[string]string{}, Services: map[string][]string{}}
+
+	for _, entry := range archive.File {
+		switch {
+		case entry.Name == "META-INF/MANIFEST.MF":
+			content, err := readEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			jar.Attributes = parseManifest(content)
+		case path.Dir(entry.Name) == "META-INF/services" && !entry.FileInfo().IsDir():
+			content, err := readEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			if providers := parseServices(content); len(providers) > 0 {
+				jar.Services[path.Base(entry.Name)] = providers
+			}
+		case strings.HasPrefix(entry.Name, "META-INF/maven/") && isPom(entry.Name):
+			content, err := readEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			jar.Poms = mergePom(jar.Poms, path.Dir(entry.Name), path.Base(entry.Name), content)
+		case path.Dir(entry.Name) == "META-INF" && strings.HasPrefix(strings.ToUpper(path.Base(entry.Name)), "LICENSE"):
+			content, err := readEntry(entry)
+			if err != nil {
+				return nil, err
+			}
+			if license := License(content); license != "" {
+				jar.Licenses = append(jar.Licenses, license)
+			}
+		case strings.HasSuffix(entry.Name, ".class") && !strings.HasPrefix(entry.Name, "META-INF/"):
+			if name := strings.TrimSuffix(entry.Name, ".class"); path.Base(name) != "module-info" {
+				jar.Classes = append(jar.Classes, strings.ReplaceAll(name, "/", "."))
+			}
+		}
+	}
+
+	jar.Title = first(jar.Attributes, "Implementation-Title", "Bundle-Name", "Specification-Title")
+	jar.Version = first(jar.Attributes, "Implementation-Version", "Bundle-Version", "Specification-Version")
+	if bundle := jar.Attributes["Bundle-License"]; bundle != "" {
+		// Bundle-License is a list of "name;link=url" clauses
+		for _, clause := range strings.Split(bundle, ",") {
+			name, _, _ := strings.Cut(clause, ";")
+			if license := License(name); license != "" {
+				jar.Licenses = append(jar.Licenses, license)
+			}
+		}
+	}
+	jar.Licenses = unique(jar.Licenses)
+	return jar, nil
+}
+
+// Interfaces returns the SPI interfaces implemented by the JAR, sorted by name.
+func (jar *Jar) Interfaces() []string {
+	interfaces := make([]string, 0, len(jar.Services))
+	for name := range jar.Services {
+		interfaces = append(interfaces, name)
+	}
+	sort.Strings(interfaces)
+	return interfaces
+}
+
+// readEntry reads a single file from a JAR.
+func readEntry(entry *zip.File) (string, error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", entry.Name, err)
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", entry.Name, err)
+	}
+	return string(content), nil
+}
+
+// parseManifest reads the main section of a JAR manifest, joining continuation lines.
+func parseManifest(content string) map[string]string {
+	attributes := map[string]string{}
+	var last string
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			// The main section ends at the first blank line
+			break
+		}
+
+		if strings.HasPrefix(line, " ") && last != "" {
+			attributes[last] += line[1:]
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		last = strings.TrimSpace(name)
+		attributes[last] = strings.TrimSpace(value)
+	}
+
+	return attributes
+}
+
+// parseServices reads the provider class names from a META-INF/services file, ignoring comments.
+func parseServices(content string) []string {
+	var providers []string
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		if line = strings.TrimSpace(line); line != "" {
+			providers = append(providers, line)
+		}
+	}
+	return providers
+}
+
+// first returns the first non-empty attribute among names.
+func first(attributes map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := attributes[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
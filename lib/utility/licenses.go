@@ -0,0 +1,46 @@
+package utility
+
+import (
+	"regexp"
+	"strings"
+)
+
+// licenses maps patterns found in license texts, names and URLs to SPDX identifiers. More specific patterns come first.
+var licenses = []struct {
+	id      string
+	pattern *regexp.Regexp
+}{
+	{"Apache-2.0", regexp.MustCompile(`(?is)apache.*2\.0|apache-2\.0`)},
+	{"LGPL-3.0-only", regexp.MustCompile(`(?is)lesser general public license.*version 3|lgpl-?3`)},
+	{"LGPL-2.1-only", regexp.MustCompile(`(?is)lesser general public license.*version 2\.1|lgpl-?2\.1`)},
+	{"AGPL-3.0-only", regexp.MustCompile(`(?is)affero general public license.*version 3|agpl-?3`)},
+	{"GPL-3.0-only", regexp.MustCompile(`(?is)general public license.*version 3|\bgpl-?3`)},
+	{"GPL-2.0-only", regexp.MustCompile(`(?is)general public license.*version 2|\bgpl-?2`)},
+	{"EPL-2.0", regexp.MustCompile(`(?is)eclipse public license.*2\.0|epl-?2\.0`)},
+	{"EPL-1.0", regexp.MustCompile(`(?is)eclipse public license.*1\.0|epl-?1\.0`)},
+	{"MPL-2.0", regexp.MustCompile(`(?is)mozilla public license.*2\.0|mpl-?2\.0`)},
+	{"MIT", regexp.MustCompile(`(?i)\bMIT\b|permission is hereby granted, free of charge`)},
+	{"BSD-3-Clause", regexp.MustCompile(`(?is)bsd[ -]3|redistributions.*neither the name`)},
+	{"BSD-2-Clause", regexp.MustCompile(`(?is)bsd[ -]2|redistributions of source code must retain`)},
+}
+
+// License identifies a license from its text, name or URL, returning its SPDX identifier,
+// or an empty string if it isn't recognised.
+func License(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+
+	// Only the start of a license file names it; the rest may mention others
+	if len(text) > 2048 {
+		text = text[:2048]
+	}
+
+	for _, license := range licenses {
+		if license.pattern.MatchString(text) {
+			return license.id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+package utility
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Override records a manifest value defined by several files, and which of them was used.
+type Override struct {
+	Path Location
+	// File is the manifest whose value is used.
+	File string
+	// Previous is the manifest whose value was replaced.
+	Previous string
+}
+
+// Resolved is the effective manifest merged from one or more files and everything they include.
+type Resolved struct {
+	// Settings holds the merged manifest, as read from the files, without their include lists.
+	Settings map[string]any
+	// Files lists every manifest read, from lowest to highest precedence; the last is the primary manifest.
+	Files []string
+	// Overrides lists the values replaced by a manifest of higher precedence.
+	Overrides []Override
+	// origins maps each merged value (see units) to the manifest it came from.
+	origins map[string]string
+}
+
+// Resolve merges manifests in order of increasing precedence. Each manifest's include list is merged in first,
+// recursively, so a manifest overrides what it includes.
+//
+// Plugins are merged by key, and the entries of targets, network, files, hooks and profiles by name: an entry
+// defined again replaces the earlier one as a whole. Other values are replaced as a whole. With strict, the files have
+// no declared precedence and a value defined differently by two of them is an error; their includes still apply.
+func Resolve(files []string, strict bool) (*Resolved, error) {
+	resolved := &Resolved{Settings: map[string]any{}, origins: map[string]string{}}
+	for _, file := range files {
+		layer := &Resolved{Settings: map[string]any{}, origins: map[string]string{}}
+		if err := layer.include(file, nil); err != nil {
+			return nil, err
+		}
+
+		resolved.Files = append(resolved.Files, layer.Files...)
+		resolved.Overrides = append(resolved.Overrides, layer.Overrides...)
+
+		collisions := resolved.merge(layer.Settings, layer.origins)
+		if strict && len(collisions) > 0 {
+			messages := make([]string, 0, len(collisions))
+			for _, collision := range collisions {
+				messages = append(messages, fmt.Sprintf("%s is defined by both %s and %s", collision.Path, collision.Previous, collision.File))
+			}
+			return nil, fmt.Errorf("manifests collide:\n- %s\n\nPass them with --manifest, in order of precedence, to merge them anyway", strings.Join(messages, "\n- "))
+		}
+		resolved.Overrides = append(resolved.Overrides, collisions...)
+	}
+	return resolved, nil
+}
+
+// include merges a manifest's includes, then the manifest itself. stack holds the manifests being included,
+// to detect cycles.
+func (resolved *Resolved) include(file string, stack []string) error {
+	absolute, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	for _, parent := range stack {
+		if parent == absolute {
+			return fmt.Errorf("manifest %s includes itself through %s", file, strings.Join(stack, " -> "))
+		}
+	}
+
+	settings, err := Settings(file)
+	if err != nil {
+		return err
+	}
+
+	var includes []string
+	switch value := settings["include"].(type) {
+	case nil:
+	case string:
+		includes = strings.Split(value, ",")
+	case []any:
+		for _, item := range value {
+			includes = append(includes, fmt.Sprint(item))
+		}
+	default:
+		return fmt.Errorf("include in %s must be a list of files", file)
+	}
+	delete(settings, "include")
+
+	for _, included := range includes {
+		included = strings.TrimSpace(included)
+		if !filepath.IsAbs(included) {
+			included = filepath.Join(filepath.Dir(file), included)
+		}
+		if err := resolved.include(included, append(stack, absolute)); err != nil {
+			return err
+		}
+	}
+
+	origins := map[string]string{}
+	for _, unit := range units(settings) {
+		origins[unit.String()] = file
+	}
+
+	resolved.Files = append(resolved.Files, file)
+	resolved.Overrides = append(resolved.Overrides, resolved.merge(settings, origins)...)
+	return nil
+}
+
+// merge applies settings over the resolved ones, returning the values that were defined differently before.
+func (resolved *Resolved) merge(settings map[string]any, origins map[string]string) []Override {
+	var overrides []Override
+	for _, unit := range units(settings) {
+		value := lookup(settings, unit)
+		if existing, found := resolved.lookup(unit); found && !reflect.DeepEqual(existing, value) {
+			overrides = append(overrides, Override{Path: unit, File: origins[unit.String()], Previous: resolved.origins[unit.String()]})
+		}
+
+		if len(unit) == 1 {
+			resolved.Settings[unit[0]] = value
+		} else {
+			object, ok := resolved.Settings[unit[0]].(map[string]any)
+			if !ok {
+				object = map[string]any{}
+				resolved.Settings[unit[0]] = object
+			}
+			object[unit[1]] = value
+		}
+		resolved.origins[unit.String()] = origins[unit.String()]
+	}
+	return overrides
+}
+
+// lookup returns the resolved value at a unit.
+func (resolved *Resolved) lookup(unit Location) (any, bool) {
+	if len(unit) == 1 {
+		value, found := resolved.Settings[unit[0]]
+		return value, found
+	}
+	object, _ := resolved.Settings[unit[0]].(map[string]any)
+	value, found := object[unit[1]]
+	return value, found
+}
+
+// Origin returns the manifest that defines a location, or the primary manifest if none does.
+func (resolved *Resolved) Origin(location Location) string {
+	for n := len(location); n > 0; n-- {
+		if file, found := resolved.origins[location[:n].String()]; found {
+			return file
+		}
+	}
+	return resolved.Primary()
+}
+
+// Primary returns the manifest of highest precedence, which changes to the manifest are written to.
+func (resolved *Resolved) Primary() string {
+	if len(resolved.Files) == 0 {
+		return ""
+	}
+	return resolved.Files[len(resolved.Files)-1]
+}
+
+// units lists the values of settings that are merged as a whole: each entry of the objects of lib.Manifest
+// (plugins, targets, network, files, hooks and profiles), and every other top-level value. They are sorted.
+func units(settings map[string]any) []Location {
+	var locations []Location
+	for key, value := range settings {
+		object, isObject := value.(map[string]any)
+		kind := reflect.Invalid
+		if t := child(manifestType, key); t != nil {
+			kind = t.Kind()
+		}
+
+		if !isObject || (kind != reflect.Map && kind != reflect.Struct) {
+			locations = append(locations, Location{key})
+			continue
+		}
+		for name := range object {
+			locations = append(locations, Location{key, name})
+		}
+	}
+
+	sort.Slice(locations, func(i, j int) bool { return locations[i].String() < locations[j].String() })
+	return locations
+}
+
+// lookup returns the value of settings at a unit.
+func lookup(settings map[string]any, unit Location) any {
+	if len(unit) == 1 {
+		return settings[unit[0]]
+	}
+	return settings[unit[0]].(map[string]any)[unit[1]]
+}
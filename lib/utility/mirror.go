@@ -0,0 +1,81 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Release describes a single mirrored release and the artifacts available for it.
+type Release struct {
+	Key       string     `json:"key"`
+	Tag       string     `json:"tag"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Artifact describes a single mirrored file.
+type Artifact struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+}
+
+// MirrorDirectory returns the directory within a mirror root that holds a plugin's release,
+// following the {owner}/{repo}/releases/download/{tag} layout requested by Restore.
+func MirrorDirectory(root string, key string, plugin lib.Plugin) (string, error) {
+	relative := filepath.Join(filepath.FromSlash(key), "releases", "download", plugin.Tag)
+	if !filepath.IsLocal(relative) || !filepath.IsLocal(plugin.Artifact) {
+		return "", fmt.Errorf("refusing to mirror %s outside of %s", key, root)
+	}
+
+	return filepath.Join(root, relative), nil
+}
+
+// Index lists every release found in a mirror root, sorted by key and tag.
+func Index(root string) ([]Release, error) {
+	pattern := filepath.Join(root, "*", "*", "releases", "download", "*")
+	directories, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan mirror %s: %w", root, err)
+	}
+
+	releases := make([]Release, 0, len(directories))
+	for _, directory := range directories {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", directory, err)
+		}
+
+		relative, _ := filepath.Rel(root, directory)
+		parts := strings.Split(filepath.ToSlash(relative), "/")
+		release := Release{Key: parts[0] + "/" + parts[1], Tag: parts[4], Artifacts: []Artifact{}}
+
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || filepath.Ext(entry.Name()) == ".partial" {
+				continue
+			}
+
+			release.Artifacts = append(release.Artifacts, Artifact{
+				Name: entry.Name(),
+				Size: info.Size(),
+				URL:  "/" + filepath.ToSlash(filepath.Join(relative, entry.Name())),
+			})
+		}
+
+		if len(release.Artifacts) > 0 {
+			releases = append(releases, release)
+		}
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Key != releases[j].Key {
+			return releases[i].Key < releases[j].Key
+		}
+		return releases[i].Tag < releases[j].Tag
+	})
+	return releases, nil
+}
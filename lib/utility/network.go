@@ -0,0 +1,164 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Client builds the HTTP client shared by every download from the manifest's network settings.
+// Connect, TLS handshake and response header timeouts are applied to the transport.
+// If no proxy is configured, the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are honoured.
+func Client(network lib.Network) (*http.Client, error) {
+	config, err := tlsConfig(network)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := proxyFunc(network)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: Duration(network.ConnectTimeout, DefaultConnectTimeout), KeepAlive: 30 * time.Second}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = config
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = Duration(network.TLSTimeout, DefaultTLSTimeout)
+	transport.ResponseHeaderTimeout = Duration(network.HeaderTimeout, DefaultHeaderTimeout)
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// Duration returns value, or fallback if the manifest leaves it unset.
+func Duration(value *time.Duration, fallback time.Duration) time.Duration {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
+
+// tlsConfig assembles the TLS settings: extra root CAs, an optional client certificate and a minimum version.
+func tlsConfig(network lib.Network) (*tls.Config, error) {
+	config := &tls.Config{}
+
+	if network.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		bundle, err := os.ReadFile(network.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle %s: %w", network.CABundle, err)
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", network.CABundle)
+		}
+		config.RootCAs = pool
+	}
+
+	if network.ClientCert != "" || network.ClientKey != "" {
+		if network.ClientCert == "" || network.ClientKey == "" {
+			return nil, fmt.Errorf("client-cert and client-key must be set together")
+		}
+
+		certificate, err := tls.LoadX509KeyPair(network.ClientCert, network.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if network.MinTLS != "" {
+		versions := map[string]uint16{
+			"1.0": tls.VersionTLS10,
+			"1.1": tls.VersionTLS11,
+			"1.2": tls.VersionTLS12,
+			"1.3": tls.VersionTLS13,
+		}
+
+		version, ok := versions[strings.TrimSpace(network.MinTLS)]
+		if !ok {
+			return nil, fmt.Errorf("unsupported min-tls version %q (expected 1.0, 1.1, 1.2 or 1.3)", network.MinTLS)
+		}
+		config.MinVersion = version
+	}
+
+	return config, nil
+}
+
+// proxyFunc returns the proxy selector for the transport, bypassing the proxy for hosts matched by no-proxy.
+func proxyFunc(network lib.Network) (func(*http.Request) (*url.URL, error), error) {
+	if network.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	proxy, err := url.Parse(network.Proxy)
+	if err != nil || proxy.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", network.Proxy)
+	}
+
+	exclusions := strings.Split(network.NoProxy, ",")
+
+	return func(request *http.Request) (*url.URL, error) {
+		if bypass(request.URL, exclusions) {
+			return nil, nil
+		}
+		return proxy, nil
+	}, nil
+}
+
+// bypass reports whether target matches any no-proxy entry.
+// Entries may be "*", a host name (matching its subdomains too), an IP address or a CIDR range, optionally with a port.
+func bypass(target *url.URL, exclusions []string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" && target.Scheme == "https" {
+		port = "443"
+	} else if port == "" {
+		port = "80"
+	}
+	ip := net.ParseIP(host)
+
+	for _, exclusion := range exclusions {
+		exclusion = strings.ToLower(strings.TrimSpace(exclusion))
+		switch {
+		case exclusion == "":
+			continue
+		case exclusion == "*":
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(exclusion); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		name := exclusion
+		if h, p, err := net.SplitHostPort(exclusion); err == nil {
+			if p != port {
+				continue
+			}
+			name = h
+		}
+
+		name = strings.TrimPrefix(strings.TrimPrefix(name, "*"), ".")
+		if host == name || strings.HasSuffix(host, "."+name) {
+			return true
+		}
+	}
+
+	return false
+}
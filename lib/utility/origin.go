@@ -0,0 +1,218 @@
+package utility
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pom describes a Maven project bundled in a JAR, from its pom.properties and pom.xml.
+type Pom struct {
+	// Dir is the META-INF/maven/{groupId}/{artifactId} directory the files were found in.
+	Dir        string
+	GroupID    string
+	ArtifactID string
+	Version    string
+	// URLs holds the project and SCM URLs declared in pom.xml.
+	URLs []string
+	// Licenses lists the licenses declared in pom.xml, see License.
+	Licenses []string
+}
+
+// pomXML is the subset of a pom.xml used to locate a project's repository.
+type pomXML struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	URL        string `xml:"url"`
+	SCM        struct {
+		URL                 string `xml:"url"`
+		Connection          string `xml:"connection"`
+		DeveloperConnection string `xml:"developerConnection"`
+	} `xml:"scm"`
+	Parent struct {
+		GroupID string `xml:"groupId"`
+		Version string `xml:"version"`
+	} `xml:"parent"`
+	Licenses []struct {
+		Name string `xml:"name"`
+		URL  string `xml:"url"`
+	} `xml:"licenses>license"`
+}
+
+// repository matches a GitHub repository in a URL or SCM connection string.
+var repository = regexp.MustCompile(`github\.com[/:]([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+)`)
+
+// versioned matches the version at the end of a JAR file name, e.g. "my-plugin-1.2.3.jar".
+var versioned = regexp.MustCompile(`-v?(\d[\w.+-]*)\.jar$`)
+
+// isPom reports whether a JAR entry is one of the files Maven bundles about a project.
+func isPom(name string) bool {
+	base := path.Base(name)
+	return base == "pom.properties" || base == "pom.xml"
+}
+
+// mergePom adds what a pom.properties or pom.xml file declares to the project in the same directory.
+func mergePom(poms []Pom, dir string, base string, content string) []Pom {
+	index := -1
+	for i := range poms {
+		if poms[i].Dir == dir {
+			index = i
+		}
+	}
+	if index < 0 {
+		poms = append(poms, Pom{Dir: dir})
+		index = len(poms) - 1
+	}
+	pom := &poms[index]
+
+	if base == "pom.properties" {
+		for _, line := range strings.Split(content, "\n") {
+			name, value, found := strings.Cut(strings.TrimSpace(line), "=")
+			if !found || strings.HasPrefix(name, "#") {
+				continue
+			}
+			switch strings.TrimSpace(name) {
+			case "groupId":
+				pom.GroupID = strings.TrimSpace(value)
+			case "artifactId":
+				pom.ArtifactID = strings.TrimSpace(value)
+			case "version":
+				pom.Version = strings.TrimSpace(value)
+			}
+		}
+		return poms
+	}
+
+	project := pomXML{}
+	if err := xml.Unmarshal([]byte(content), &project); err != nil {
+		return poms
+	}
+	if pom.GroupID == "" {
+		pom.GroupID = project.GroupID
+		if pom.GroupID == "" {
+			pom.GroupID = project.Parent.GroupID
+		}
+	}
+	if pom.ArtifactID == "" {
+		pom.ArtifactID = project.ArtifactID
+	}
+	if pom.Version == "" && !strings.Contains(project.Version, "${") {
+		pom.Version = project.Version
+		if pom.Version == "" {
+			pom.Version = project.Parent.Version
+		}
+	}
+	for _, declared := range project.Licenses {
+		if license := License(declared.Name + " " + declared.URL); license != "" {
+			pom.Licenses = append(pom.Licenses, license)
+		}
+	}
+	for _, url := range []string{project.SCM.URL, project.SCM.Connection, project.SCM.DeveloperConnection, project.URL} {
+		if url != "" {
+			pom.URLs = append(pom.URLs, url)
+		}
+	}
+	return poms
+}
+
+// Project returns the Maven project a JAR was most likely built from: the one whose artifactId starts its file name,
+// or the only one bundled. Shaded JARs bundle their dependencies' poms too, so anything else is ambiguous.
+func (jar *Jar) Project(file string) *Pom {
+	for i := range jar.Poms {
+		if jar.Poms[i].ArtifactID != "" && strings.HasPrefix(path.Base(file), jar.Poms[i].ArtifactID) {
+			return &jar.Poms[i]
+		}
+	}
+	if len(jar.Poms) == 1 {
+		return &jar.Poms[0]
+	}
+	return nil
+}
+
+// Origins guesses the GitHub repositories ("owner/repo") a JAR may have been released from, most likely first.
+// Repository URLs in its Maven project and manifest attributes are used, then io.github/com.github group IDs.
+func Origins(jar *Jar, file string) []string {
+	var texts []string
+	project := jar.Project(file)
+	if project != nil {
+		texts = append(texts, project.URLs...)
+	}
+	for _, name := range []string{"Implementation-URL", "Bundle-DocURL", "Bundle-SCM", "Implementation-Vendor-Id"} {
+		texts = append(texts, jar.Attributes[name])
+	}
+
+	var origins []string
+	for _, text := range texts {
+		for _, match := range repository.FindAllStringSubmatch(text, -1) {
+			origins = append(origins, match[1]+"/"+strings.TrimSuffix(match[2], ".git"))
+		}
+	}
+
+	if project != nil && project.ArtifactID != "" {
+		for _, prefix := range []string{"io.github.", "com.github."} {
+			if owner, found := strings.CutPrefix(project.GroupID, prefix); found && owner != "" {
+				owner, _, _ = strings.Cut(owner, ".")
+				origins = append(origins, owner+"/"+project.ArtifactID)
+			}
+		}
+	}
+
+	return Distinct(origins)
+}
+
+// Tags guesses the release tags a JAR may have been published under, from its Maven, manifest and file name versions.
+func Tags(jar *Jar, file string) []string {
+	var versions []string
+	if project := jar.Project(file); project != nil {
+		versions = append(versions, project.Version)
+	}
+	versions = append(versions, jar.Version)
+	if match := versioned.FindStringSubmatch(path.Base(file)); match != nil {
+		versions = append(versions, match[1])
+	}
+
+	var tags []string
+	for _, version := range versions {
+		if version = strings.TrimPrefix(strings.TrimSpace(version), "v"); version != "" {
+			tags = append(tags, "v"+version, version)
+		}
+	}
+	return Distinct(tags)
+}
+
+// RemoteChecksum downloads url without saving it and returns its SHA3-512, or false if it does not exist.
+func RemoteChecksum(ctx context.Context, client *http.Client, url string) (string, bool, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", false, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return "", false, err
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(response.Body)
+
+	if response.StatusCode == http.StatusNotFound {
+		return "", false, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("bad status code: %d", response.StatusCode)
+	}
+
+	sha3 := crypto.SHA3_512.New()
+	if _, err := io.Copy(sha3, response.Body); err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(sha3.Sum(nil)), true, nil
+}
@@ -0,0 +1,302 @@
+package utility
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Advisory is the subset of an OSV vulnerability record used to audit plugins.
+// See https://ossf.github.io/osv-schema/.
+type Advisory struct {
+	ID       string     `json:"id"`
+	Summary  string     `json:"summary"`
+	Aliases  []string   `json:"aliases"`
+	Severity []Score    `json:"severity"`
+	Affected []Affected `json:"affected"`
+	Database struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// Score is a severity score such as a CVSS vector.
+type Score struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+// Affected describes the versions of one package an advisory applies to.
+type Affected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+		Purl      string `json:"purl"`
+	} `json:"package"`
+	Ranges   []Range  `json:"ranges"`
+	Versions []string `json:"versions"`
+	Severity []Score  `json:"severity"`
+	Database struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// Range is a sequence of events introducing and fixing a vulnerability.
+type Range struct {
+	Type   string  `json:"type"`
+	Events []Event `json:"events"`
+}
+
+// Event is a version at which a range introduces or fixes a vulnerability. Only one of its fields is set.
+type Event struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+// version returns the version an event happens at, "0" standing for before every version.
+func (event Event) version() string {
+	switch {
+	case event.Introduced != "":
+		return event.Introduced
+	case event.Fixed != "":
+		return event.Fixed
+	}
+	return event.LastAffected
+}
+
+// sortedEvents returns a range's events ordered by version, as the OSV schema requires before evaluating them.
+// An introduced event comes before any other event at the same version.
+func sortedEvents(events []Event) []Event {
+	sorted := append([]Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Introduced == "0" || b.Introduced == "0" {
+			return a.Introduced == "0" && b.Introduced != "0"
+		}
+		if order := CompareVersions(a.version(), b.version()); order != 0 {
+			return order < 0
+		}
+		return a.Introduced != "" && b.Introduced == ""
+	})
+	return sorted
+}
+
+// Severities orders the severity levels an audit can report, from least to most severe.
+var Severities = []string{"unknown", "low", "medium", "high", "critical"}
+
+// Database is a local OSV dump, indexed by package.
+type Database struct {
+	// packages maps "ecosystem:name" (lowercase) to the advisories affecting it.
+	packages map[string][]*Advisory
+	// Count is the number of advisories loaded.
+	Count int
+}
+
+// LoadOSV reads every advisory in an OSV dump: a directory of JSON records (searched recursively),
+// or a zip archive of them such as the per-ecosystem all.zip exports.
+func LoadOSV(path string) (*Database, error) {
+	db := &Database{packages: map[string][]*Advisory{}}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OSV database: %w", err)
+	}
+
+	if !info.IsDir() {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open OSV database %s: %w", path, err)
+		}
+		defer func(archive *zip.ReadCloser) {
+			_ = archive.Close()
+		}(archive)
+
+		for _, entry := range archive.File {
+			if strings.HasSuffix(entry.Name, ".json") {
+				if err := db.load(entry.Name, entry.Open); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return db, nil
+	}
+
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(file, ".json") {
+			return err
+		}
+		return db.load(file, func() (io.ReadCloser, error) { return os.Open(file) })
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// load decodes a single advisory and indexes it under every package it affects.
+func (db *Database) load(name string, open func() (io.ReadCloser, error)) error {
+	reader, err := open()
+	if err != nil {
+		return err
+	}
+	defer func(reader io.ReadCloser) {
+		_ = reader.Close()
+	}(reader)
+
+	advisory := &Advisory{}
+	if err := json.NewDecoder(reader).Decode(advisory); err != nil {
+		return fmt.Errorf("invalid OSV record %s: %w", name, err)
+	}
+
+	db.Count++
+	seen := map[string]bool{}
+	for _, affected := range advisory.Affected {
+		var keys []string
+		if affected.Package.Name != "" {
+			keys = append(keys, packageKey(affected.Package.Ecosystem, affected.Package.Name))
+		}
+		if purl := affected.Package.Purl; purl != "" {
+			keys = append(keys, packageKey("purl", strings.Split(purl, "@")[0]))
+		}
+
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				db.packages[key] = append(db.packages[key], advisory)
+			}
+		}
+	}
+	return nil
+}
+
+// Match returns the advisories affecting a version of a package, e.g. ("Maven", "org.example:lib", "1.2.0").
+// Packages may also be looked up by purl without a version, with the "purl" ecosystem.
+func (db *Database) Match(ecosystem string, name string, version string) []*Advisory {
+	var matches []*Advisory
+	for _, advisory := range db.packages[packageKey(ecosystem, name)] {
+		for _, affected := range advisory.Affected {
+			if !affected.is(ecosystem, name) {
+				continue
+			}
+			if affected.Affects(version) {
+				matches = append(matches, advisory)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// is reports whether the affected entry describes the package.
+func (affected Affected) is(ecosystem string, name string) bool {
+	if ecosystem == "purl" {
+		return strings.EqualFold(strings.Split(affected.Package.Purl, "@")[0], name)
+	}
+	return strings.EqualFold(affected.Package.Ecosystem, ecosystem) && strings.EqualFold(affected.Package.Name, name)
+}
+
+// Affects reports whether a version is listed explicitly or falls within one of the ECOSYSTEM or SEMVER ranges.
+// Range events are evaluated in version order, whatever their order in the record. GIT ranges are made of
+// commits, which can't be compared to release versions, and are ignored.
+func (affected Affected) Affects(version string) bool {
+	for _, listed := range affected.Versions {
+		if listed == version || strings.TrimPrefix(listed, "v") == strings.TrimPrefix(version, "v") {
+			return true
+		}
+	}
+
+	for _, span := range affected.Ranges {
+		if span.Type == "GIT" {
+			continue
+		}
+
+		vulnerable := false
+		for _, event := range sortedEvents(span.Events) {
+			switch {
+			case event.Introduced != "":
+				if event.Introduced == "0" || CompareVersions(version, event.Introduced) >= 0 {
+					vulnerable = true
+				}
+			case event.Fixed != "":
+				if CompareVersions(version, event.Fixed) >= 0 {
+					vulnerable = false
+				}
+			case event.LastAffected != "":
+				if CompareVersions(version, event.LastAffected) > 0 {
+					vulnerable = false
+				}
+			}
+		}
+		if vulnerable {
+			return true
+		}
+	}
+	return false
+}
+
+// Level returns the severity of an advisory as one of Severities: the database's own rating when it has one
+// (as GitHub advisories do), otherwise the rating of its highest CVSS v3 score.
+func (advisory *Advisory) Level() string {
+	ratings := []string{advisory.Database.Severity}
+	scores := advisory.Severity
+	for _, affected := range advisory.Affected {
+		ratings = append(ratings, affected.Database.Severity)
+		scores = append(scores, affected.Severity...)
+	}
+
+	for _, rating := range ratings {
+		switch strings.ToLower(rating) {
+		case "low":
+			return "low"
+		case "moderate", "medium":
+			return "medium"
+		case "high":
+			return "high"
+		case "critical":
+			return "critical"
+		}
+	}
+
+	best := -1.0
+	for _, score := range scores {
+		if score.Type != "CVSS_V3" {
+			continue
+		}
+		if base, err := CVSS3(score.Score); err == nil && base > best {
+			best = base
+		}
+	}
+
+	switch {
+	case best >= 9:
+		return "critical"
+	case best >= 7:
+		return "high"
+	case best >= 4:
+		return "medium"
+	case best > 0:
+		return "low"
+	}
+	return "unknown"
+}
+
+// SeverityRank returns the position of a severity level in Severities, or -1 if it is not one.
+func SeverityRank(level string) int {
+	for i, severity := range Severities {
+		if strings.EqualFold(severity, level) {
+			return i
+		}
+	}
+	return -1
+}
+
+// packageKey builds the index key of a package.
+func packageKey(ecosystem string, name string) string {
+	return strings.ToLower(ecosystem + ":" + name)
+}
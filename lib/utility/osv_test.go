@@ -0,0 +1,54 @@
+package utility
+
+import "testing"
+
+func TestAffects(t *testing.T) {
+	introduced := func(version string) Event { return Event{Introduced: version} }
+	fixed := func(version string) Event { return Event{Fixed: version} }
+	last := func(version string) Event { return Event{LastAffected: version} }
+
+	tests := []struct {
+		name    string
+		events  []Event
+		version string
+		want    bool
+	}{
+		{"before introduced", []Event{introduced("1.0.0"), fixed("1.2.0")}, "0.9.0", false},
+		{"at introduced", []Event{introduced("1.0.0"), fixed("1.2.0")}, "1.0.0", true},
+		{"within range", []Event{introduced("1.0.0"), fixed("1.2.0")}, "1.1.5", true},
+		{"at fixed", []Event{introduced("1.0.0"), fixed("1.2.0")}, "1.2.0", false},
+		{"from the start", []Event{introduced("0"), fixed("2.0.0")}, "0.0.1", true},
+		{"never fixed", []Event{introduced("0")}, "99.0.0", true},
+		{"at last affected", []Event{introduced("1.0.0"), last("1.4.0")}, "1.4.0", true},
+		{"after last affected", []Event{introduced("1.0.0"), last("1.4.0")}, "1.4.1", false},
+		{"pre-release of fix", []Event{introduced("1.0.0"), fixed("1.2.0")}, "1.2.0-rc1", true},
+		{"out of order, within", []Event{fixed("1.2.0"), introduced("1.0.0")}, "1.1.0", true},
+		{"out of order, fixed", []Event{fixed("1.2.0"), introduced("1.0.0")}, "1.3.0", false},
+		{"out of order, zero", []Event{fixed("2.0.0"), introduced("0")}, "1.0.0", true},
+		{"reintroduced", []Event{introduced("1.0.0"), fixed("1.2.0"), introduced("2.0.0"), fixed("2.1.0")}, "2.0.5", true},
+		{"between ranges", []Event{introduced("1.0.0"), fixed("1.2.0"), introduced("2.0.0"), fixed("2.1.0")}, "1.5.0", false},
+		{"shuffled, between", []Event{fixed("2.1.0"), introduced("2.0.0"), fixed("1.2.0"), introduced("1.0.0")}, "1.5.0", false},
+		{"shuffled, second", []Event{fixed("2.1.0"), introduced("2.0.0"), fixed("1.2.0"), introduced("1.0.0")}, "2.0.0", true},
+	}
+
+	for _, test := range tests {
+		affected := Affected{Ranges: []Range{{Type: "ECOSYSTEM", Events: test.events}}}
+		if got := affected.Affects(test.version); got != test.want {
+			t.Errorf("%s: Affects(%q) = %v, want %v", test.name, test.version, got, test.want)
+		}
+	}
+}
+
+func TestAffectsVersionsAndGit(t *testing.T) {
+	affected := Affected{
+		Versions: []string{"v1.0.0"},
+		Ranges:   []Range{{Type: "GIT", Events: []Event{{Introduced: "0"}}}},
+	}
+
+	if !affected.Affects("1.0.0") {
+		t.Error("Affects(1.0.0) = false, want true for a listed version")
+	}
+	if affected.Affects("1.1.0") {
+		t.Error("Affects(1.1.0) = true, want GIT ranges to be ignored")
+	}
+}
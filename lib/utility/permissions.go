@@ -0,0 +1,163 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Permissions is the mode and ownership applied to installed files and the directories created for them.
+// A zero mode leaves the default in place, and a UID or GID of -1 leaves ownership unchanged.
+type Permissions struct {
+	Mode    os.FileMode
+	DirMode os.FileMode
+	UID     int
+	GID     int
+}
+
+// NewPermissions resolves the manifest's file settings, looking up user and group names if needed.
+// The owner may also be given as "user:group".
+func NewPermissions(files lib.Files) (Permissions, error) {
+	permissions := Permissions{UID: -1, GID: -1}
+
+	var err error
+	if permissions.Mode, err = parseMode(files.Mode); err != nil {
+		return permissions, fmt.Errorf("invalid file mode: %w", err)
+	}
+	if permissions.DirMode, err = parseMode(files.DirMode); err != nil {
+		return permissions, fmt.Errorf("invalid directory mode: %w", err)
+	}
+
+	owner, group := files.Owner, files.Group
+	if name, rest, found := strings.Cut(owner, ":"); found {
+		owner = name
+		if group == "" {
+			group = rest
+		}
+	}
+
+	if owner != "" {
+		if permissions.UID, err = lookupUser(owner); err != nil {
+			return permissions, err
+		}
+	}
+	if group != "" {
+		if permissions.GID, err = lookupGroup(group); err != nil {
+			return permissions, err
+		}
+	}
+
+	return permissions, nil
+}
+
+// Apply sets the file mode and ownership of an installed file.
+func (permissions Permissions) Apply(path string) error {
+	return permissions.apply(path, permissions.Mode)
+}
+
+// MkdirAll creates a directory and any missing parents, applying the directory mode and ownership to each one it creates.
+func (permissions Permissions) MkdirAll(dir string) error {
+	var missing []string
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		missing = append(missing, current)
+		if parent := filepath.Dir(current); parent == current {
+			break
+		}
+	}
+
+	mode := permissions.DirMode
+	if mode == 0 {
+		mode = 0o755
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], mode); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if err := permissions.apply(missing[i], permissions.DirMode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// apply sets the ownership (if configured) and mode (if non-zero) of a single path.
+// Ownership goes first, since changing it clears the setuid and setgid bits.
+func (permissions Permissions) apply(path string, mode os.FileMode) error {
+	if permissions.UID != -1 || permissions.GID != -1 {
+		if err := os.Lchown(path, permissions.UID, permissions.GID); err != nil {
+			return fmt.Errorf("chown %s: %w", path, err)
+		}
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("chmod %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// parseMode reads an octal permission string such as "0644", "755" or "2775". The setuid (4000), setgid (2000)
+// and sticky (1000) bits become the matching os.FileMode bits, which os.Chmod and os.Mkdir apply.
+func parseMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseUint(strings.TrimPrefix(mode, "0o"), 8, 32)
+	if err != nil || value > 0o7777 {
+		return 0, fmt.Errorf("%q is not an octal mode", mode)
+	}
+
+	result := os.FileMode(value & 0o777)
+	if value&0o4000 != 0 {
+		result |= os.ModeSetuid
+	}
+	if value&0o2000 != 0 {
+		result |= os.ModeSetgid
+	}
+	if value&0o1000 != 0 {
+		result |= os.ModeSticky
+	}
+	return result, nil
+}
+
+// lookupUser resolves a user name or numeric UID.
+func lookupUser(name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	account, err := user.Lookup(name)
+	if err != nil {
+		return -1, fmt.Errorf("unknown user %q: %w", name, err)
+	}
+	return strconv.Atoi(account.Uid)
+}
+
+// lookupGroup resolves a group name or numeric GID.
+func lookupGroup(name string) (int, error) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return id, nil
+	}
+
+	group, err := user.LookupGroup(name)
+	if err != nil {
+		return -1, fmt.Errorf("unknown group %q: %w", name, err)
+	}
+	return strconv.Atoi(group.Gid)
+}
@@ -0,0 +1,41 @@
+package utility
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want os.FileMode
+	}{
+		{"", 0},
+		{"644", 0o644},
+		{"0644", 0o644},
+		{"0o755", 0o755},
+		{"4755", 0o755 | os.ModeSetuid},
+		{"2775", 0o775 | os.ModeSetgid},
+		{"1777", 0o777 | os.ModeSticky},
+		{"07777", 0o777 | os.ModeSetuid | os.ModeSetgid | os.ModeSticky},
+	}
+
+	for _, test := range tests {
+		got, err := parseMode(test.mode)
+		if err != nil {
+			t.Errorf("parseMode(%q): unexpected error %v", test.mode, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseMode(%q) = %v, want %v", test.mode, got, test.want)
+		}
+	}
+}
+
+func TestParseModeInvalid(t *testing.T) {
+	for _, mode := range []string{"8", "rw-r--r--", "10000", "-644", "0x644"} {
+		if _, err := parseMode(mode); err == nil {
+			t.Errorf("parseMode(%q): expected an error", mode)
+		}
+	}
+}
@@ -4,42 +4,41 @@ import (
 	"cloakroom/lib"
 	"context"
 	"fmt"
-	"github.com/vbauerster/mpb/v8"
 	"os"
 	"path/filepath"
 )
 
-// Restore downloads a specified plugin from the given host to the local wardrobe directory.
+// Restore downloads a specified plugin from the given sources to the local wardrobe directory.
 // If a file already exists and force is false, it skips downloading. If force is true, it overwrites.
 // The plugin's hash (if provided) is used for optional verification.
+// It reports whether the plugin was downloaded, as opposed to skipped.
 func Restore(
 	ctx context.Context,
-	host string,
+	transfer *Transfer,
+	sources []string,
 	wardrobe string,
 	key string,
 	plugin lib.Plugin,
 	force bool,
-	progress *mpb.Progress,
-) error {
-	source := fmt.Sprintf("https://%s/%s/releases/download/%s/%s", host, key, plugin.Tag, plugin.Artifact)
+) (bool, error) {
 	destination := filepath.Join(wardrobe, plugin.Artifact)
 
 	if _, err := os.Stat(destination); err == nil {
 		if force {
 			fmt.Printf("[INFO] Removing existing file: %s\n", destination)
 			if err := os.RemoveAll(destination); err != nil {
-				return fmt.Errorf("failed to remove existing file %s: %w", destination, err)
+				return false, fmt.Errorf("failed to remove existing file %s: %w", destination, err)
 			}
 		} else {
 			fmt.Printf("[SKIP] Plugin already exists: %s (use --force to overwrite)\n", destination)
-			return nil
+			return false, nil
 		}
 	}
 
-	if err := Download(ctx, progress, source, destination, plugin.Hash, 3); err != nil {
-		return fmt.Errorf("downloading %s -> %s: %w", key, destination, err)
+	if err := Download(ctx, transfer, sources, destination, plugin.Hash); err != nil {
+		return false, fmt.Errorf("downloading %s -> %s: %w", key, destination, err)
 	}
 
 	fmt.Printf("[OK] Downloaded %s -> %s\n", key, destination)
-	return nil
+	return true, nil
 }
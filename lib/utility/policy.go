@@ -0,0 +1,170 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// PolicyName is the base name of the policy file looked up in the current directory.
+const PolicyName = Cloakroom + ".policy"
+
+// DefaultBranches are the tag names treated as branches by deny-branch-tags, unless the policy lists its own.
+var DefaultBranches = []string{"main", "master", "develop", "dev", "trunk", "HEAD", "latest", "nightly"}
+
+// Violation is a policy rule broken by the manifest.
+type Violation struct {
+	// Rule is the policy key that was broken, e.g. require-hash.
+	Rule string
+	// Path locates the offending manifest value, e.g. plugins."acme/theme".hash.
+	Path Location
+	// Where is the file and line that define the value, e.g. cloakroom.yaml:7, see utility.Where.
+	Where   string
+	Message string
+}
+
+// FindPolicy returns the policy file to enforce: the explicit path if given, then CLOAKROOM_POLICY,
+// then a cloakroom.policy.{yaml,yml,json,toml} file in the current directory. It returns "" if there is none.
+// The environment is read directly so that a manifest can never choose its own policy.
+func FindPolicy(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if env := os.Getenv("CLOAKROOM_POLICY"); env != "" {
+		return env
+	}
+	for _, extension := range []string{"yaml", "yml", "json", "toml"} {
+		candidate := PolicyName + "." + extension
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// LoadPolicy reads a policy file in any format viper supports.
+func LoadPolicy(file string) (*lib.Policy, error) {
+	reader := viper.New()
+	reader.SetConfigFile(file)
+	if err := reader.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read policy %s: %w", file, err)
+	}
+
+	policy := &lib.Policy{}
+	if err := reader.UnmarshalExact(policy); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+	}
+	return policy, nil
+}
+
+// Evaluate checks a manifest against a policy and returns every violation, ordered by location.
+// origin returns the manifest file that defines a location, which each violation is located in.
+//
+// Sources using the file scheme are denied unless the policy's schemes allow them explicitly.
+func Evaluate(policy *lib.Policy, manifest *lib.Manifest, origin func(Location) string) ([]Violation, error) {
+	var pattern *regexp.Regexp
+	if policy.TagPattern != "" {
+		compiled, err := regexp.Compile(policy.TagPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag-pattern %q: %w", policy.TagPattern, err)
+		}
+		pattern = compiled
+	}
+
+	branches := policy.Branches
+	if len(branches) == 0 {
+		branches = DefaultBranches
+	}
+
+	var violations []Violation
+	add := func(rule string, location Location, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Path: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Hosts and mirrors are checked once, where they are declared
+	check := func(location Location, base string) {
+		if strings.TrimSpace(base) == "" {
+			return
+		}
+		scheme, host := endpoint(base)
+		switch {
+		case len(policy.Schemes) > 0 && !anyMatch(policy.Schemes, scheme):
+			add("schemes", location, "scheme %q is not allowed (allowed: %s)", scheme, strings.Join(policy.Schemes, ", "))
+		case len(policy.Schemes) == 0 && scheme == "file":
+			add("schemes", location, "scheme %q is not allowed unless schemes lists it", scheme)
+		}
+		if len(policy.Hosts) > 0 && host != "" && !anyMatch(policy.Hosts, host) {
+			add("hosts", location, "host %q is not allowed (allowed: %s)", host, strings.Join(policy.Hosts, ", "))
+		}
+	}
+
+	check(Location{"host"}, manifest.Host)
+	for i, mirror := range manifest.Mirrors {
+		check(Location{"mirrors", fmt.Sprintf("[%d]", i)}, mirror)
+	}
+
+	for key, plugin := range manifest.Plugins {
+		location := func(segments ...string) Location {
+			return append(Location{"plugins", key}, segments...)
+		}
+		owner, _, _ := strings.Cut(key, "/")
+
+		if len(policy.Owners) > 0 && !anyMatch(policy.Owners, owner) {
+			add("owners", location(), "owner %q is not allowed (allowed: %s)", owner, strings.Join(policy.Owners, ", "))
+		}
+		if policy.RequireHash && (plugin.Hash == nil || strings.TrimSpace(*plugin.Hash) == "") {
+			add("require-hash", location("hash"), "plugin has no hash")
+		}
+		if policy.RequireKeycloak && strings.TrimSpace(plugin.Keycloak) == "" {
+			add("require-keycloak", location("keycloak"), "plugin has no keycloak constraint")
+		}
+		if policy.DenyBranchTags && anyMatch(branches, plugin.Tag) {
+			add("deny-branch-tags", location("tag"), "tag %q is a branch name, not a release", plugin.Tag)
+		}
+		if pattern != nil && !pattern.MatchString(plugin.Tag) {
+			add("tag-pattern", location("tag"), "tag %q does not match %s", plugin.Tag, policy.TagPattern)
+		}
+		for i, mirror := range plugin.Mirrors {
+			check(location("mirrors", fmt.Sprintf("[%d]", i)), mirror)
+		}
+	}
+
+	for i := range violations {
+		violations[i].Where = Where(origin(violations[i].Path), violations[i].Path)
+	}
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].Path.String() < violations[j].Path.String() })
+	return violations, nil
+}
+
+// endpoint returns the scheme and host name of a host, mirror URL or mirror template.
+func endpoint(base string) (string, string) {
+	base = strings.TrimSpace(base)
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+
+	parsed, err := url.Parse(strings.NewReplacer("{", "", "}", "").Replace(base))
+	if err != nil {
+		scheme, rest, _ := strings.Cut(base, "://")
+		host, _, _ := strings.Cut(rest, "/")
+		return strings.ToLower(scheme), host
+	}
+	return strings.ToLower(parsed.Scheme), parsed.Hostname()
+}
+
+// anyMatch reports whether value matches any of the glob patterns, ignoring case.
+func anyMatch(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value)); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	hash := strings.Repeat("a", 128)
+	manifest := func() *lib.Manifest {
+		return &lib.Manifest{
+			Host:    "github.com",
+			Mirrors: []string{"https://mirror.example.com/{owner}/{repo}/{tag}/{artifact}"},
+			Plugins: map[string]lib.Plugin{
+				"acme/theme": {Tag: "v1.0.0", Artifact: "theme.jar", Hash: &hash, Keycloak: ">=21"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		policy lib.Policy
+		change func(manifest *lib.Manifest)
+		want   []string
+	}{
+		{"empty policy", lib.Policy{}, nil, nil},
+		{"allowed owner", lib.Policy{Owners: []string{"acme"}}, nil, nil},
+		{"denied owner", lib.Policy{Owners: []string{"example-*"}}, nil, []string{`owners plugins."acme/theme"`}},
+		{"allowed hosts", lib.Policy{Hosts: []string{"github.com", "*.example.com"}}, nil, nil},
+		{"denied host", lib.Policy{Hosts: []string{"github.com"}}, nil, []string{"hosts mirrors[0]"}},
+		{"denied plugin mirror", lib.Policy{Hosts: []string{"github.com", "mirror.example.com"}}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Mirrors = []string{"http://evil.example.org"}
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`hosts plugins."acme/theme".mirrors[0]`}},
+		{"denied scheme", lib.Policy{Schemes: []string{"https"}}, func(m *lib.Manifest) {
+			m.Mirrors = []string{"http://mirror.internal:8080"}
+		}, []string{"schemes mirrors[0]"}},
+		{"file scheme by default", lib.Policy{}, func(m *lib.Manifest) {
+			m.Mirrors = []string{"file:///srv/mirror"}
+		}, []string{"schemes mirrors[0]"}},
+		{"file scheme allowed", lib.Policy{Schemes: []string{"https", "file"}}, func(m *lib.Manifest) {
+			m.Mirrors = []string{"file:///srv/mirror"}
+		}, nil},
+		{"require hash", lib.Policy{RequireHash: true}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Hash = nil
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`require-hash plugins."acme/theme".hash`}},
+		{"require keycloak", lib.Policy{RequireKeycloak: true}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Keycloak = ""
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`require-keycloak plugins."acme/theme".keycloak`}},
+		{"branch tag", lib.Policy{DenyBranchTags: true}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Tag = "main"
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`deny-branch-tags plugins."acme/theme".tag`}},
+		{"custom branches", lib.Policy{DenyBranchTags: true, Branches: []string{"release-*"}}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Tag = "release-1"
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`deny-branch-tags plugins."acme/theme".tag`}},
+		{"tag pattern", lib.Policy{TagPattern: `^v\d+\.\d+\.\d+$`}, func(m *lib.Manifest) {
+			plugin := m.Plugins["acme/theme"]
+			plugin.Tag = "latest"
+			m.Plugins["acme/theme"] = plugin
+		}, []string{`tag-pattern plugins."acme/theme".tag`}},
+	}
+
+	for _, test := range tests {
+		subject := manifest()
+		if test.change != nil {
+			test.change(subject)
+		}
+
+		violations, err := Evaluate(&test.policy, subject, func(Location) string { return "" })
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		var got []string
+		for _, violation := range violations {
+			got = append(got, violation.Rule+" "+violation.Path.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("%s: Evaluate() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEvaluateInvalidPattern(t *testing.T) {
+	if _, err := Evaluate(&lib.Policy{TagPattern: "("}, &lib.Manifest{}, func(Location) string { return "" }); err == nil {
+		t.Error("Evaluate() with an invalid tag-pattern: expected an error")
+	}
+}
+
+func TestEvaluateWhere(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cloakroom.yaml")
+	content := "version: \"1.0\"\nhost: github.com\nplugins:\n  acme/theme:\n    tag: main\n    artifact: theme.jar\n"
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &lib.Manifest{Host: "github.com", Plugins: map[string]lib.Plugin{"acme/theme": {Tag: "main", Artifact: "theme.jar"}}}
+	violations, err := Evaluate(&lib.Policy{DenyBranchTags: true}, manifest, func(Location) string { return file })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 1 || violations[0].Where != file+":5" {
+		t.Errorf("Evaluate() = %+v, want one violation at %s:5", violations, file)
+	}
+}
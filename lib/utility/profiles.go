@@ -0,0 +1,68 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Apply changes a manifest as its named profile describes: the host is replaced if the profile sets one,
+// plugins in remove are dropped, and the profile's plugins are added, or override the entries with the same key,
+// see overlay. The manifest's plugins are copied rather than changed in place, and it no longer lists its profiles
+// afterwards. An empty name leaves the manifest as it is.
+func Apply(manifest *lib.Manifest, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	profile, found := manifest.Profiles[name]
+	if !found {
+		available := sortedNames(manifest.Profiles)
+		if len(available) == 0 {
+			return fmt.Errorf("unknown profile %q: the manifest defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (expected one of %s)", name, strings.Join(available, ", "))
+	}
+
+	if strings.TrimSpace(profile.Host) != "" {
+		manifest.Host = profile.Host
+	}
+
+	plugins := make(map[string]lib.Plugin, len(manifest.Plugins)+len(profile.Plugins))
+	for key, plugin := range manifest.Plugins {
+		plugins[key] = plugin
+	}
+	for _, key := range profile.Remove {
+		delete(plugins, key)
+	}
+	for key, plugin := range profile.Plugins {
+		if base, found := plugins[key]; found {
+			plugin = overlay(base, plugin)
+		}
+		plugins[key] = plugin
+	}
+
+	manifest.Plugins = plugins
+	manifest.Profiles = nil
+	return nil
+}
+
+// overlay returns a plugin with the fields a profile sets replacing those of the base plugin, so a profile only
+// lists what it changes, e.g. a tag. The base hash is dropped if the profile installs a different tag or artifact
+// without giving a hash of its own, since it can't match the other file.
+func overlay(base lib.Plugin, override lib.Plugin) lib.Plugin {
+	merged := base
+	result := reflect.ValueOf(&merged).Elem()
+	fields := reflect.ValueOf(override)
+	for i := 0; i < fields.NumField(); i++ {
+		if !fields.Field(i).IsZero() {
+			result.Field(i).Set(fields.Field(i))
+		}
+	}
+
+	if override.Hash == nil && (merged.Tag != base.Tag || merged.Artifact != base.Artifact) {
+		merged.Hash = nil
+	}
+	return merged
+}
@@ -1,9 +1,11 @@
 package utility
 
 import (
+	"cloakroom/lib"
 	"context"
 	"crypto"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -17,47 +19,91 @@ import (
 	"github.com/vbauerster/mpb/v8/decor"
 )
 
-// Download downloads a file from 'url' to 'destinationPath'.
+// Transfer holds the settings shared by every download in a single run.
+type Transfer struct {
+	// Client is the shared HTTP client, see Client.
+	Client *http.Client
+	// Progress renders one bar per file being downloaded.
+	Progress *mpb.Progress
+	// Retries is how many times to retry a failed attempt with exponential backoff.
+	Retries int
+	// Timeout bounds a single attempt at downloading one artifact. Zero disables it.
+	Timeout time.Duration
+	// Stall aborts an attempt when no bytes arrive for this long. Zero disables it.
+	Stall time.Duration
+	// Permissions is applied to each downloaded file before it is moved into place.
+	Permissions Permissions
+}
+
+// ErrStalled is reported when a download attempt receives no data for longer than Transfer.Stall.
+var ErrStalled = errors.New("download stalled")
+
+// NewTransfer builds a Transfer from the manifest's network and file settings, creating the shared HTTP client once.
+func NewTransfer(manifest *lib.Manifest, progress *mpb.Progress) (*Transfer, error) {
+	network := manifest.Network
+	client, err := Client(network)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := NewPermissions(manifest.Files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transfer{
+		Client:      client,
+		Progress:    progress,
+		Retries:     3,
+		Timeout:     Duration(network.Timeout, DefaultTimeout),
+		Stall:       Duration(network.StallTimeout, DefaultStallTimeout),
+		Permissions: permissions,
+	}, nil
+}
+
+// Download downloads a file from the first working URL in 'sources' to 'destinationPath'.
 // It implements several best practices:
-//  1. Retries with exponential backoff.
+//  1. Tries each source in turn, then retries the whole list with exponential backoff.
 //  2. Downloads to a temporary .partial file, then renames on success.
-//  3. (Optional) Verifies the file's SHA3-512 checksum if non-empty.
+//  3. (Optional) Verifies the file's SHA3-512 checksum before renaming, so every source must serve the same bytes.
+//     The configured mode and ownership are also applied before renaming.
 //  4. Tracks progress via a progress bar.
-//  5. Respects context cancellation.
+//  5. Respects context cancellation, removing the partial file on the way out.
+//  6. Treats attempts that time out or stall as retryable failures.
 //
 // Arguments:
 //   - ctx: to allow cancellation (e.g., from signals or parent context).
-//   - progress: mpb.Progress pointer for multi-file progress bar handling.
-//   - url: the direct download URL.
+//   - transfer: the client, progress container, retry and timeout settings for this run.
+//   - sources: the direct download URLs, in order of preference (see Sources).
 //   - destination: full path of the final file on disk.
 //   - hash: if not empty, verifies the downloaded file matches this checksum (hex-encoded).
-//   - retries: how many times to attempt with exponential backoff.
 //
 // Returns an error if something goes wrong or if checksum verification fails.
 func Download(
 	ctx context.Context,
-	progress *mpb.Progress,
-	url string,
+	transfer *Transfer,
+	sources []string,
 	destination string,
 	hash *string,
-	retries int,
 ) error {
+	retries := transfer.Retries
 
 	// Create the final directory if needed
-	if err := os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+	if err := transfer.Permissions.MkdirAll(filepath.Dir(destination)); err != nil {
 		return fmt.Errorf("mkdir failed for %s: %v", filepath.Dir(destination), err)
 	}
 
 	// Partial file handling
 	partial := destination + ".partial"
-	if _, err := os.Stat(partial); err != nil {
-		return fmt.Errorf("download failed for %s: %v", partial, err)
-	}
-
 	if err := os.RemoveAll(partial); err != nil {
-		return fmt.Errorf("failed to remove existing partial file %s: %w", destination, err)
+		return fmt.Errorf("failed to remove existing partial file %s: %w", partial, err)
 	}
 
+	// Never leave a partial file behind, whether we failed or were interrupted
+	defer func() {
+		_ = os.Remove(partial)
+	}()
+
 	// For the progress bar labeling
 	filename := filepath.Base(destination)
 
@@ -73,33 +119,51 @@ func Download(
 		default:
 		}
 
-		// Begin the single download attempt
-		lastErr = fetch(ctx, progress, url, partial, filename)
-		if lastErr == nil {
-			// If the download succeeded, rename the partial file => final destination
-			if err := os.Rename(partial, destination); err != nil {
-				return fmt.Errorf("rename failed: %w", err)
+		// Begin the single download attempt, falling back through the sources in order
+		for index, source := range sources {
+			if index > 0 {
+				fmt.Printf("[WARN] %s: falling back to %s after error: %v\n", filename, source, lastErr)
+			}
+
+			lastErr = fetch(ctx, transfer, source, partial, filename)
+			if lastErr == nil && hash != nil {
+				// If we have a checksum, verify it before the file is put in place
+				lastErr = Verify(partial, *hash)
 			}
+			if lastErr == nil {
+				// Set mode and ownership first, so the file never appears with the wrong ones
+				if err := transfer.Permissions.Apply(partial); err != nil {
+					return err
+				}
 
-			// If we have a checksum, verify it
-			if hash != nil {
-				if err := verify(destination, *hash); err != nil {
-					lastErr = err
-				} else {
-					return nil
+				// If the download succeeded, rename the partial file => final destination
+				if err := os.Rename(partial, destination); err != nil {
+					return fmt.Errorf("rename failed: %w", err)
 				}
-			} else {
 				return nil
 			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 		}
 
 		// If we reach here, either the download or checksum failed
 		//  => we’ll retry if attempt < retries
 		if attempt < retries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
 			backoff = exponentialBackoff(attempt)
 			fmt.Printf("[Retry %d/%d] Retrying in %s due to error: %v\n",
 				attempt+1, retries, backoff, lastErr)
-			time.Sleep(backoff)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
 		}
 	}
 
@@ -108,17 +172,31 @@ func Download(
 
 // fetch performs a single attempt at downloading the file into partialPath.
 // It also creates/updates a progress bar for the read operation.
-func fetch(ctx context.Context, p *mpb.Progress, url, partialPath, fileLabel string) error {
+// The attempt is abandoned if it exceeds the transfer's timeout or stalls.
+func fetch(ctx context.Context, transfer *Transfer, url, partialPath, fileLabel string) error {
 	// Remove any leftover partial file before starting fresh
 	_ = os.Remove(partialPath)
 
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	if transfer.Timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeoutCause(ctx, transfer.Timeout,
+			fmt.Errorf("attempt exceeded timeout of %s", transfer.Timeout))
+		defer stop()
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := transfer.Client.Do(req)
 	if err != nil {
+		if cause := context.Cause(ctx); cause != nil && ctx.Err() != nil {
+			return fmt.Errorf("HTTP GET failed: %w", cause)
+		}
 		return fmt.Errorf("HTTP GET failed: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
@@ -152,7 +230,7 @@ func fetch(ctx context.Context, p *mpb.Progress, url, partialPath, fileLabel str
 	}
 
 	// Create a bar for this file download
-	bar := p.AddBar(
+	bar := transfer.Progress.AddBar(
 		totalSize,
 		mpb.PrependDecorators(
 			// Use a fixed width so multi-file downloads line up well
@@ -165,8 +243,8 @@ func fetch(ctx context.Context, p *mpb.Progress, url, partialPath, fileLabel str
 		),
 	)
 
-	// Wrap resp.Body with the bar’s ProxyReader
-	reader := bar.ProxyReader(resp.Body)
+	// Wrap resp.Body with the stall detector and the bar’s ProxyReader
+	reader := bar.ProxyReader(watch(resp.Body, transfer.Stall, cancel))
 	defer func(reader io.ReadCloser) {
 		err := reader.Close()
 		if err != nil {
@@ -174,21 +252,76 @@ func fetch(ctx context.Context, p *mpb.Progress, url, partialPath, fileLabel str
 		}
 	}(reader)
 
-	// Copy to disk
+	// Copy to disk, shutting the bar down if the copy fails or is interrupted
 	if _, err := io.Copy(out, reader); err != nil {
+		bar.Abort(false)
+		if cause := context.Cause(ctx); cause != nil && ctx.Err() != nil {
+			return fmt.Errorf("io copy failed: %w", cause)
+		}
 		return fmt.Errorf("io copy failed: %w", err)
 	}
 
 	// If we got here, it means the download completed successfully
+	bar.SetTotal(-1, true)
 	return nil
 }
 
-// verify checks the SHA-256 of the downloaded file
+// stallReader cancels the attempt when no bytes have been read within the stall window.
+type stallReader struct {
+	io.Reader
+	window time.Duration
+	timer  *time.Timer
+}
+
+// watch wraps body with a stall detector that calls cancel with ErrStalled. A zero window disables it.
+func watch(body io.Reader, window time.Duration, cancel context.CancelCauseFunc) io.Reader {
+	if window <= 0 {
+		return body
+	}
+
+	timer := time.AfterFunc(window, func() {
+		cancel(fmt.Errorf("%w: no data received for %s", ErrStalled, window))
+	})
+	return &stallReader{Reader: body, window: window, timer: timer}
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.window)
+	}
+	if err != nil {
+		r.timer.Stop()
+	}
+	return n, err
+}
+
+// Verify checks the SHA3-512 of the downloaded file
 // against the expected hex-encoded string. Returns an error if mismatched.
-func verify(filePath, expectedHex string) error {
+func Verify(filePath, expectedHex string) error {
+	actualHex, err := Checksum(filePath)
+	if err != nil {
+		return err
+	}
+
+	// Normalize uppercase vs. lowercase
+	expectedHex = strings.ToLower(strings.TrimSpace(expectedHex))
+	if actualHex != expectedHex {
+		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHex, actualHex)
+	}
+	return nil
+}
+
+// Checksum returns the hex-encoded SHA3-512 of a file, as used for plugin hashes.
+func Checksum(filePath string) (string, error) {
+	return Digest(filePath, crypto.SHA3_512)
+}
+
+// Digest returns the hex-encoded digest of a file using the given hash function.
+func Digest(filePath string, hash crypto.Hash) (string, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("open for checksum: %w", err)
+		return "", fmt.Errorf("open for checksum: %w", err)
 	}
 	defer func(f *os.File) {
 		err := f.Close()
@@ -197,18 +330,11 @@ func verify(filePath, expectedHex string) error {
 		}
 	}(f)
 
-	sha3 := crypto.SHA3_512.New()
-	if _, err := io.Copy(sha3, f); err != nil {
-		return fmt.Errorf("copy for checksum: %w", err)
+	digest := hash.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", fmt.Errorf("copy for checksum: %w", err)
 	}
-	actualHex := hex.EncodeToString(sha3.Sum(nil))
-
-	// Normalize uppercase vs. lowercase
-	expectedHex = strings.ToLower(strings.TrimSpace(expectedHex))
-	if actualHex != expectedHex {
-		return fmt.Errorf("checksum mismatch: expected %s, got %s", expectedHex, actualHex)
-	}
-	return nil
+	return hex.EncodeToString(digest.Sum(nil)), nil
 }
 
 // exponentialBackoff returns a simple exponential backoff duration
@@ -0,0 +1,212 @@
+package utility
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Component is a plugin, or a library bundled inside one, as it appears in an SBOM.
+type Component struct {
+	Name    string
+	Group   string
+	Version string
+	Purl    string
+	// URL is where the component was downloaded from, if it was downloaded on its own.
+	URL string
+	// Hashes maps SPDX algorithm names (e.g. SHA256) to hex digests.
+	Hashes map[string]string
+	// Licenses holds SPDX identifiers, see License.
+	Licenses []string
+	// Nested lists the libraries shaded into the component.
+	Nested []Component
+}
+
+// GitHubPurl returns the package URL of a GitHub release, e.g. pkg:github/owner/repo@v1.0.0.
+func GitHubPurl(key string, tag string) string {
+	owner, repo, _ := strings.Cut(key, "/")
+	return fmt.Sprintf("pkg:github/%s/%s@%s", url.PathEscape(owner), url.PathEscape(repo), url.PathEscape(tag))
+}
+
+// MavenPurl returns the package URL of a Maven artifact, e.g. pkg:maven/org.example/lib@1.0.0.
+func MavenPurl(pom Pom) string {
+	return fmt.Sprintf("pkg:maven/%s/%s@%s", url.PathEscape(pom.GroupID), url.PathEscape(pom.ArtifactID), url.PathEscape(pom.Version))
+}
+
+// cycloneAlgorithms maps SPDX hash algorithm names to CycloneDX ones.
+var cycloneAlgorithms = map[string]string{"SHA1": "SHA-1", "SHA256": "SHA-256", "SHA512": "SHA-512", "SHA3-512": "SHA3-512"}
+
+// CycloneDX builds a CycloneDX 1.5 JSON document listing the components, with shaded libraries nested under them.
+func CycloneDX(name string, components []Component) map[string]any {
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.5",
+		"serialNumber": "urn:uuid:" + uuid(),
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"tools": map[string]any{
+				"components": []any{map[string]any{"type": "application", "name": Cloakroom}},
+			},
+			"component": map[string]any{"type": "application", "bom-ref": name, "name": name},
+		},
+		"components": cyclone(components, ""),
+	}
+}
+
+// cyclone converts components to their CycloneDX representation. Nested components are referenced through
+// their parent, since the same library may be shaded into several plugins.
+func cyclone(components []Component, parent string) []any {
+	result := make([]any, 0, len(components))
+	for _, component := range components {
+		reference := component.Purl
+		if parent != "" {
+			reference = parent + "|" + component.Purl
+		}
+
+		entry := map[string]any{
+			"type":    "library",
+			"bom-ref": reference,
+			"name":    component.Name,
+			"purl":    component.Purl,
+		}
+		if component.Version != "" {
+			entry["version"] = component.Version
+		}
+		if component.Group != "" {
+			entry["group"] = component.Group
+		}
+
+		var hashes []any
+		for _, algorithm := range sortedKeys(component.Hashes) {
+			if name, found := cycloneAlgorithms[algorithm]; found {
+				hashes = append(hashes, map[string]any{"alg": name, "content": component.Hashes[algorithm]})
+			}
+		}
+		if len(hashes) > 0 {
+			entry["hashes"] = hashes
+		}
+
+		if len(component.Licenses) > 0 {
+			licenses := make([]any, 0, len(component.Licenses))
+			for _, license := range component.Licenses {
+				licenses = append(licenses, map[string]any{"license": map[string]any{"id": license}})
+			}
+			entry["licenses"] = licenses
+		}
+
+		if component.URL != "" {
+			entry["externalReferences"] = []any{map[string]any{"type": "distribution", "url": component.URL}}
+		}
+		if len(component.Nested) > 0 {
+			entry["components"] = cyclone(component.Nested, reference)
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// SPDX builds an SPDX 2.3 JSON document with a package per component. Shaded libraries are separate packages
+// that the plugin CONTAINS.
+func SPDX(name string, components []Component) map[string]any {
+	// Empty, not nil, so a manifest without plugins still lists them as [] rather than null
+	packages, relationships := []any{}, []any{}
+	var add func(component Component, parent string)
+	add = func(component Component, parent string) {
+		id := fmt.Sprintf("SPDXRef-Package-%d", len(packages)+1)
+
+		download := component.URL
+		if download == "" {
+			download = "NOASSERTION"
+		}
+		declared := "NOASSERTION"
+		if len(component.Licenses) > 0 {
+			declared = strings.Join(component.Licenses, " AND ")
+		}
+
+		entry := map[string]any{
+			"SPDXID":           id,
+			"name":             component.Name,
+			"downloadLocation": download,
+			"filesAnalyzed":    false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared":  declared,
+			"copyrightText":    "NOASSERTION",
+			"externalRefs": []any{map[string]any{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  component.Purl,
+			}},
+		}
+		if component.Version != "" {
+			entry["versionInfo"] = component.Version
+		}
+		if component.Group != "" {
+			entry["supplier"] = "Organization: " + component.Group
+		}
+
+		var checksums []any
+		for _, algorithm := range sortedKeys(component.Hashes) {
+			checksums = append(checksums, map[string]any{"algorithm": algorithm, "checksumValue": component.Hashes[algorithm]})
+		}
+		if len(checksums) > 0 {
+			entry["checksums"] = checksums
+		}
+
+		packages = append(packages, entry)
+		relationship := "DESCRIBES"
+		if parent != "SPDXRef-DOCUMENT" {
+			relationship = "CONTAINS"
+		}
+		relationships = append(relationships, map[string]any{
+			"spdxElementId":      parent,
+			"relationshipType":   relationship,
+			"relatedSpdxElement": id,
+		})
+
+		for _, nested := range component.Nested {
+			add(nested, id)
+		}
+	}
+
+	for _, component := range components {
+		add(component, "SPDXRef-DOCUMENT")
+	}
+
+	id := uuid()
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              name,
+		"documentNamespace": "https://spdx.org/spdxdocs/" + Cloakroom + "-" + id,
+		"creationInfo": map[string]any{
+			"created":  time.Now().UTC().Format(time.RFC3339),
+			"creators": []string{"Tool: " + Cloakroom},
+		},
+		"packages":      packages,
+		"relationships": relationships,
+	}
+}
+
+// uuid returns a random (version 4) UUID.
+func uuid() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// sortedKeys returns the keys of a map, sorted.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
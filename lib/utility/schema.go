@@ -0,0 +1,184 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaURL is where the manifest JSON Schema is published. New manifests reference it for editor support.
+const SchemaURL = "https://raw.githubusercontent.com/peter-mghendi/cloakroom/main/cloakroom.schema.json"
+
+// SchemaDirectives maps manifest formats to the comment that points editors at the schema.
+// JSON manifests use a "$schema" key instead; HCL and INI have no convention.
+var SchemaDirectives = map[string]string{
+	"yaml": "# yaml-language-server: $schema=",
+	"toml": "#:schema ",
+}
+
+// descriptions documents the manifest fields in the schema, by type and key.
+var descriptions = map[string]string{
+	"Manifest.version":  `Manifest version. The only valid value is "1.0".`,
+	"Manifest.host":     `A host compatible with the GitHub releases format, e.g. "github.com", or the URL of a GitHub or Gitea server.`,
+	"Manifest.keycloak": `The target Keycloak version, e.g. "26.1.0", used to check plugin compatibility.`,
+	"Manifest.mirrors":  "Fallback hosts or URL templates tried, in order, when host fails.",
+	"Manifest.plugins":  "The plugins to install, keyed by owner/repo.",
+	"Manifest.network":  "Connection settings shared by every download.",
+	"Manifest.hooks":    "Local commands run at each stage of the plugin lifecycle.",
+	"Manifest.files":    "Mode and ownership applied to every installed artifact.",
+	"Manifest.targets":  "Named install directories, in addition to the default providers target.",
+	"Manifest.include":  "Other manifests merged in before this one, relative to it. This manifest takes precedence.",
+	"Manifest.profiles": "Environment profiles, selected with --profile or CLOAKROOM_PROFILE.",
+	"Manifest.wardrobe": "The providers directory, unless CLOAKROOM_WARDROBE or targets.providers is set.",
+	"Manifest.kc_home":  "The Keycloak installation, used to detect its version and find kc.sh, unless KC_HOME is set.",
+	"Manifest.$schema":  "The JSON Schema of this manifest, for editors.",
+
+	"Profile.host":    "Replaces the manifest host.",
+	"Profile.plugins": "Plugins added by the profile, or fields overriding those of the manifest entry with the same key.",
+	"Profile.remove":  "Keys of manifest plugins the profile doesn't install.",
+
+	"Plugin.tag":              `The release tag, e.g. "v1.2.0".`,
+	"Plugin.artifact":         "The file name of the release asset.",
+	"Plugin.hash":             "The hex SHA3-512 digest of the artifact.",
+	"Plugin.mirrors":          "Fallback hosts for this plugin only, tried before the global mirrors.",
+	"Plugin.keycloak":         `The Keycloak versions this plugin supports, e.g. "21", ">=21, <22" or "~21.1 || ^22".`,
+	"Plugin.hooks":            "Commands run for this plugin once it is installed.",
+	"Plugin.target":           "The install target, one of targets. Defaults to providers.",
+	"Plugin.archive":          "Extract the artifact instead of installing it as-is.",
+	"Plugin.extract":          "Glob patterns selecting the archive entries to extract, matched after stripping. Defaults to every file.",
+	"Plugin.strip-components": "Leading path components removed from archive entries.",
+
+	"Network.proxy":           "Proxy URL for every download.",
+	"Network.no-proxy":        "Comma-separated hosts, domains and CIDR ranges that bypass the proxy.",
+	"Network.ca-bundle":       "PEM file of additional trusted certificate authorities.",
+	"Network.client-cert":     "PEM client certificate for mutual TLS.",
+	"Network.client-key":      "PEM private key of the client certificate.",
+	"Network.min-tls":         "Minimum TLS version.",
+	"Network.connect-timeout": "Timeout for establishing connections, e.g. 30s.",
+	"Network.tls-timeout":     "Timeout for TLS handshakes.",
+	"Network.header-timeout":  "Timeout for response headers.",
+	"Network.timeout":         "Timeout for a whole download.",
+	"Network.stall-timeout":   "Timeout for a download that stops receiving data.",
+
+	"Files.mode":     `Octal mode for installed files, e.g. "0644".`,
+	"Files.dir-mode": `Octal mode for created directories, e.g. "0755".`,
+	"Files.owner":    "User name or UID owning installed files, optionally user:group.",
+	"Files.group":    "Group name or GID owning installed files.",
+}
+
+// constraints adds the rules validation enforces to the schema, by type and key. Values that reference environment
+// variables are only checked once interpolated, so patterns accept ${...} references as they are written.
+var constraints = map[string]map[string]any{
+	"Manifest.version":        {"enum": []string{ManifestVersion}},
+	"Manifest.host":           {"minLength": 1},
+	"Manifest.plugins":        {"propertyNames": map[string]any{"pattern": pluginKey.String()}},
+	"Profile.plugins":         {"propertyNames": map[string]any{"pattern": pluginKey.String()}},
+	"Plugin.tag":              {"minLength": 1, "pattern": `^(\S|\$\{[^}]*\})+$`},
+	"Plugin.artifact":         {"minLength": 1, "pattern": `^[^/\\]+$`, "not": map[string]any{"enum": []string{".", ".."}}},
+	"Plugin.hash":             {"pattern": `^([0-9A-Fa-f]{128}|.*\$\{.*\}.*)?$`},
+	"Plugin.archive":          {"enum": []string{"zip", "tar", "tar.gz", "tgz"}},
+	"Plugin.strip-components": {"minimum": 0},
+	"Network.min-tls":         {"enum": []string{"1.0", "1.1", "1.2", "1.3"}},
+	"Files.mode":              {"pattern": `^(0o?)?[0-7]{1,4}$`},
+	"Files.dir-mode":          {"pattern": `^(0o?)?[0-7]{1,4}$`},
+}
+
+// required lists the keys each type must declare.
+var required = map[string][]string{
+	"Manifest": {"version", "host"},
+	"Plugin":   {"tag", "artifact"},
+}
+
+// Schema returns a JSON Schema (draft-07) for the manifest, generated from lib.Manifest and the types it holds.
+// Nested structures are shared as definitions, and fields unknown to cloakroom are rejected as they are by Validate.
+func Schema() map[string]any {
+	definitions := map[string]any{}
+	root := schemaOf(reflect.TypeOf(lib.Manifest{}), definitions, true)
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["$id"] = SchemaURL
+	root["title"] = "Cloakroom manifest"
+	root["definitions"] = definitions
+
+	// Keys outside lib.Manifest that validation accepts all the same
+	properties := root["properties"].(map[string]any)
+	for key := range ambient {
+		properties[key] = map[string]any{"type": "string", "description": descriptions["Manifest."+key]}
+	}
+
+	// A profile plugin overriding a manifest entry only lists what it changes, so it requires nothing
+	override := map[string]any{}
+	for rule, value := range definitions["plugin"].(map[string]any) {
+		if rule != "required" {
+			override[rule] = value
+		}
+	}
+	definitions["plugin-override"] = override
+	plugins := definitions["profile"].(map[string]any)["properties"].(map[string]any)["plugins"].(map[string]any)
+	plugins["additionalProperties"] = map[string]any{"$ref": "#/definitions/plugin-override"}
+	return root
+}
+
+// schemaOf describes a Go type, adding the structures it holds to definitions. The root structure is described
+// inline; every other structure is referenced.
+func schemaOf(t reflect.Type, definitions map[string]any, root bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return map[string]any{"type": "string", "pattern": `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Int:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), definitions, false)}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), definitions, false)}
+	case t.Kind() != reflect.Struct:
+		return map[string]any{}
+	}
+
+	name := strings.ToLower(t.Name())
+	if !root {
+		if _, found := definitions[name]; !found {
+			definitions[name] = schemaOf(t, definitions, true)
+		}
+		return map[string]any{"$ref": "#/definitions/" + name}
+	}
+
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		key := t.Field(i).Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+
+		property := schemaOf(t.Field(i).Type, definitions, false)
+		if _, reference := property["$ref"]; reference {
+			// Siblings of $ref are ignored in draft-07, so the description wraps it
+			property = map[string]any{"allOf": []any{property}}
+		}
+		if description, found := descriptions[t.Name()+"."+key]; found {
+			property["description"] = description
+		}
+		for rule, value := range constraints[t.Name()+"."+key] {
+			property[rule] = value
+		}
+		properties[key] = property
+	}
+
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if keys := required[t.Name()]; len(keys) > 0 {
+		schema["required"] = keys
+	}
+	return schema
+}
@@ -0,0 +1,70 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"fmt"
+	"strings"
+)
+
+// Layout is the GitHub releases URL layout appended to hosts and mirrors that are not templates.
+const Layout = "{owner}/{repo}/releases/download/{tag}/{artifact}"
+
+// Sources returns the ordered list of URLs a plugin may be downloaded from:
+// the manifest host first, then the plugin's own mirrors, then the manifest's global mirrors.
+func Sources(manifest *lib.Manifest, key string, plugin lib.Plugin) ([]string, error) {
+	bases := append([]string{manifest.Host}, plugin.Mirrors...)
+	bases = append(bases, manifest.Mirrors...)
+
+	sources := make([]string, 0, len(bases))
+	seen := make(map[string]bool, len(bases))
+	for _, base := range bases {
+		if strings.TrimSpace(base) == "" {
+			continue
+		}
+
+		source, err := Source(base, manifest.Host, key, plugin)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no host or mirrors configured for %s", key)
+	}
+	return sources, nil
+}
+
+// Source expands a single host or mirror into a download URL for the plugin.
+//
+// A base may be:
+//   - a bare host such as "github.com", downloaded over HTTPS using the GitHub releases layout;
+//   - a URL such as "http://mirror.internal:8080/github", to which the GitHub releases layout is appended;
+//   - a template containing any of {host}, {key}, {owner}, {repo}, {tag} and {artifact}, used as-is once expanded.
+func Source(base string, host string, key string, plugin lib.Plugin) (string, error) {
+	owner, repo, found := strings.Cut(key, "/")
+	if !found || owner == "" || repo == "" {
+		return "", fmt.Errorf("invalid plugin key %q (expected owner/repo)", key)
+	}
+
+	template := strings.TrimSpace(base)
+	if !strings.Contains(template, "{") {
+		if !strings.Contains(template, "://") {
+			template = "https://" + template
+		}
+		template = strings.TrimSuffix(template, "/") + "/" + Layout
+	}
+
+	replacer := strings.NewReplacer(
+		"{host}", host,
+		"{key}", key,
+		"{owner}", owner,
+		"{repo}", repo,
+		"{tag}", plugin.Tag,
+		"{artifact}", plugin.Artifact,
+	)
+	return replacer.Replace(template), nil
+}
@@ -0,0 +1,143 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultTarget is the target plugins are installed into when they don't name one.
+// Its directory is the wardrobe.
+const DefaultTarget = "providers"
+
+// Targets returns the directory of every named install target.
+// The default target is always present, and points at the wardrobe when one is set, which takes precedence
+// over the manifest's own providers target. Its directory is "" if neither is set.
+func Targets(manifest *lib.Manifest, wardrobe string) map[string]string {
+	targets := make(map[string]string, len(manifest.Targets)+1)
+	for name, dir := range manifest.Targets {
+		targets[name] = dir
+	}
+
+	if wardrobe != "" || targets[DefaultTarget] == "" {
+		targets[DefaultTarget] = wardrobe
+	}
+	return targets
+}
+
+// TargetNames returns the names of every install target, sorted.
+func TargetNames(targets map[string]string) []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TargetOf returns the name of the target a plugin is installed into.
+func TargetOf(plugin lib.Plugin) string {
+	if plugin.Target == "" {
+		return DefaultTarget
+	}
+	return plugin.Target
+}
+
+// Directory returns the directory a plugin is installed into.
+func Directory(manifest *lib.Manifest, wardrobe string, plugin lib.Plugin) (string, error) {
+	name := TargetOf(plugin)
+	targets := Targets(manifest, wardrobe)
+	if _, found := targets[name]; !found {
+		return "", fmt.Errorf("unknown target %q for %s", name, plugin.Artifact)
+	}
+	return TargetDirectory(targets, name)
+}
+
+// TargetDirectory returns the directory of a named target, failing if none is configured.
+func TargetDirectory(targets map[string]string, name string) (string, error) {
+	dir := targets[name]
+	if dir == "" && name == DefaultTarget {
+		return "", errors.New("no directory configured for the providers target (set CLOAKROOM_WARDROBE or targets.providers)")
+	}
+	if dir == "" {
+		return "", fmt.Errorf("no directory configured for target %q", name)
+	}
+	return dir, nil
+}
+
+// Managed returns the files the manifest installs into a target, mapped to the key of the plugin that owns each one.
+// Paths are relative to the target directory. Archive plugins own their receipt and every file it records in dir.
+func Managed(manifest *lib.Manifest, target string, dir string) map[string]string {
+	managed := map[string]string{}
+	for key, plugin := range manifest.Plugins {
+		if TargetOf(plugin) != target {
+			continue
+		}
+		if !IsArchive(plugin) {
+			managed[plugin.Artifact] = key
+			continue
+		}
+
+		managed[ReceiptName(plugin)] = key
+		if receipt, err := ReadReceipt(dir, plugin); err == nil {
+			for _, file := range receipt.Files {
+				managed[filepath.FromSlash(file.Path)] = key
+			}
+		}
+	}
+	return managed
+}
+
+// Select returns the requested targets, or the fallback targets if none are requested, failing on unknown names.
+func Select(targets map[string]string, requested []string, fallback ...string) ([]string, error) {
+	if len(requested) == 0 {
+		requested = fallback
+	}
+
+	for _, name := range requested {
+		if _, found := targets[name]; !found {
+			return nil, fmt.Errorf("unknown target %q", name)
+		}
+	}
+	return requested, nil
+}
+
+// Unmanaged lists the entries directly inside a target directory that the manifest does not install,
+// given the managed paths returned by Managed. A directory is managed if any managed path lies within it.
+func Unmanaged(dir string, managed map[string]string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	var unmanaged []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, found := managed[name]; found {
+			continue
+		}
+
+		contains := false
+		if entry.IsDir() {
+			for path := range managed {
+				if strings.HasPrefix(filepath.ToSlash(path), name+"/") {
+					contains = true
+					break
+				}
+			}
+		}
+
+		if !contains {
+			unmanaged = append(unmanaged, name)
+		}
+	}
+
+	return unmanaged, nil
+}
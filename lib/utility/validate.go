@@ -0,0 +1,449 @@
+package utility
+
+import (
+	"bufio"
+	"bytes"
+	"cloakroom/lib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// ManifestVersion is the only manifest version cloakroom understands.
+const ManifestVersion = "1.0"
+
+// Location is the path to a value in a manifest, one key per segment. List indices are segments such as "[0]".
+type Location []string
+
+// bare matches the keys that print without quotes.
+var bare = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// String renders a location as a path such as plugins."acme/theme".tag or mirrors[0].
+func (location Location) String() string {
+	var builder strings.Builder
+	for i, segment := range location {
+		switch {
+		case strings.HasPrefix(segment, "["):
+			builder.WriteString(segment)
+			continue
+		case i > 0:
+			builder.WriteString(".")
+		}
+
+		if bare.MatchString(segment) {
+			builder.WriteString(segment)
+		} else {
+			builder.WriteString(fmt.Sprintf("%q", segment))
+		}
+	}
+	return builder.String()
+}
+
+// Problem is a manifest value that fails validation.
+type Problem struct {
+	Path    Location
+	Message string
+}
+
+// Settings reads a manifest file on its own, without defaults or environment variables.
+// Top-level keys of INI manifests, which viper files under a "default" section, are moved back to the top level,
+// and the blocks of HCL manifests, which viper reads as lists of objects, are merged back into objects.
+func Settings(file string) (map[string]any, error) {
+	reader := viper.New()
+	reader.SetConfigFile(file)
+	if err := reader.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", file, err)
+	}
+
+	settings := reader.AllSettings()
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".ini":
+		if section, ok := settings["default"].(map[string]any); ok {
+			delete(settings, "default")
+			for key, value := range section {
+				settings[key] = value
+			}
+		}
+	case ".hcl":
+		settings = blocks(settings).(map[string]any)
+	}
+	return settings, nil
+}
+
+// blocks merges the lists of objects HCL blocks are decoded into, e.g. one entry per plugins "owner/repo" block.
+func blocks(value any) any {
+	switch value := value.(type) {
+	case []map[string]any:
+		merged := map[string]any{}
+		for _, block := range value {
+			for key, nested := range block {
+				merged[key] = blocks(nested)
+			}
+		}
+		return merged
+	case map[string]any:
+		for key, nested := range value {
+			value[key] = blocks(nested)
+		}
+		return value
+	}
+	return value
+}
+
+// Decode converts manifest settings into a manifest the way viper.Unmarshal does, ignoring unknown fields.
+func Decode(settings map[string]any, manifest *lib.Manifest) error {
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           manifest,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// ambient lists the top-level keys that aren't part of lib.Manifest but may still appear in the manifest.
+var ambient = map[string]bool{Wardrobe: true, KeycloakHome: true, "$schema": true}
+
+// Structure checks that a manifest file decodes into lib.Manifest: without unknown fields, and with values of
+// the right type. Problems are ordered by location. Check validates the values themselves, once merged.
+func Structure(file string) ([]Problem, error) {
+	settings, err := Settings(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	for key, value := range settings {
+		if ambient[key] {
+			continue
+		}
+		problems = append(problems, unknown(value, manifestType, Location{key})...)
+	}
+
+	var decoding *mapstructure.Error
+	if err := Decode(settings, &lib.Manifest{}); errors.As(err, &decoding) {
+		for _, message := range decoding.Errors {
+			problems = append(problems, Problem{Path: field(message), Message: message})
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	Sort(problems)
+	return problems, nil
+}
+
+// Sort orders problems by location.
+func Sort(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Path.String() < problems[j].Path.String() })
+}
+
+// unknown reports the keys of a decoded value that have no matching field in the type it is decoded into.
+// value is the value found at location, and t the type of the structure holding it.
+func unknown(value any, t reflect.Type, location Location) []Problem {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	name := location[len(location)-1]
+	for i := 0; i < t.NumField(); i++ {
+		if strings.EqualFold(t.Field(i).Tag.Get("mapstructure"), name) {
+			return fields(value, t.Field(i).Type, location)
+		}
+	}
+	return []Problem{{Path: location, Message: "unknown field"}}
+}
+
+// fields checks the keys nested in a value decoded into type t.
+func fields(value any, t reflect.Type, location Location) []Problem {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	entries, ok := value.(map[string]any)
+	if !ok || t == reflect.TypeOf(time.Duration(0)) {
+		return nil
+	}
+
+	var problems []Problem
+	for key, entry := range entries {
+		nested := append(append(Location{}, location...), key)
+		switch t.Kind() {
+		case reflect.Struct:
+			problems = append(problems, unknown(entry, t, nested)...)
+		case reflect.Map:
+			problems = append(problems, fields(entry, t.Elem(), nested)...)
+		}
+	}
+	return problems
+}
+
+// field extracts the location of a decoding error such as "'network' expected a map, got 'slice'".
+// Only the top-level key is kept, since nested names are ambiguous once keys contain dots.
+func field(message string) Location {
+	if !strings.HasPrefix(message, "'") {
+		return Location{}
+	}
+	name, _, _ := strings.Cut(message[1:], "'")
+	name, _, _ = strings.Cut(name, ".")
+	name, _, _ = strings.Cut(name, "[")
+	if name == "" {
+		return Location{}
+	}
+	return Location{name}
+}
+
+// pluginKey matches the "owner/repo" keys of plugins.
+var pluginKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// sha3Digest matches a hex SHA3-512 digest.
+var sha3Digest = regexp.MustCompile(`^[0-9A-Fa-f]{128}$`)
+
+// Check validates the values of a decoded manifest and its profiles: required fields, plugin keys, artifact names,
+// hashes, archive settings, targets, constraints and modes. Missing fields are reported on the value that should
+// hold them. The manifest is expected to be interpolated already; each profile is interpolated as it is applied,
+// and its values that can't be are reported instead of checked.
+func Check(manifest *lib.Manifest) []Problem {
+	var problems []Problem
+	add := func(location Location, format string, args ...any) {
+		problems = append(problems, Problem{Path: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch strings.TrimSpace(manifest.Version) {
+	case "":
+		add(Location{}, "version is required")
+	case ManifestVersion:
+	default:
+		add(Location{"version"}, "unsupported version %q (expected %q)", manifest.Version, ManifestVersion)
+	}
+
+	if strings.TrimSpace(manifest.Host) == "" {
+		add(Location{}, "host is required")
+	}
+	if constraint := strings.TrimSpace(manifest.Keycloak); constraint != "" {
+		if _, err := Satisfies("0.0.0", constraint); err != nil {
+			add(Location{"keycloak"}, "%v", err)
+		}
+	}
+	for i, mirror := range manifest.Mirrors {
+		if strings.TrimSpace(mirror) == "" {
+			add(Location{"mirrors", fmt.Sprintf("[%d]", i)}, "mirror is empty")
+		}
+	}
+
+	for name, dir := range manifest.Targets {
+		if strings.TrimSpace(dir) == "" {
+			add(Location{"targets", name}, "target directory is empty")
+		}
+	}
+
+	if _, err := parseMode(manifest.Files.Mode); err != nil {
+		add(Location{"files", "mode"}, "%v", err)
+	}
+	if _, err := parseMode(manifest.Files.DirMode); err != nil {
+		add(Location{"files", "dir-mode"}, "%v", err)
+	}
+
+	problems = append(problems, checkPlugins(manifest, manifest.Plugins, manifest.Plugins, Location{"plugins"})...)
+
+	for _, name := range sortedNames(manifest.Profiles) {
+		profile := manifest.Profiles[name]
+		location := Location{"profiles", name}
+
+		for i, key := range profile.Remove {
+			if _, found := manifest.Plugins[key]; !found {
+				add(append(location, "remove", fmt.Sprintf("[%d]", i)), "plugin %q is not in the manifest", key)
+			}
+		}
+
+		// The profile is interpolated in a copy, as unmarshal does once it is applied, leaving the manifest as it is
+		interpolated := profile
+		interpolated.Plugins = make(map[string]lib.Plugin, len(profile.Plugins))
+		for key, plugin := range profile.Plugins {
+			interpolated.Plugins[key] = plugin
+		}
+		failures := interpolate(reflect.ValueOf(&interpolated.Host).Elem(), append(location, "host"))
+		failures = append(failures, interpolate(reflect.ValueOf(interpolated.Plugins), append(location, "plugins"))...)
+		problems = append(problems, failures...)
+
+		effective := *manifest
+		effective.Profiles = map[string]lib.Profile{name: interpolated}
+		if err := Apply(&effective, name); err != nil {
+			add(location, "%v", err)
+			continue
+		}
+
+		// Plugins the profile overrides are checked as they end up, so only those it adds need a tag and artifact
+		declared := make(map[string]lib.Plugin, len(profile.Plugins))
+		for key := range profile.Plugins {
+			declared[key] = effective.Plugins[key]
+		}
+		failed := make(map[string]bool, len(failures))
+		for _, failure := range failures {
+			failed[failure.Path.String()] = true
+		}
+		for _, problem := range checkPlugins(&effective, declared, effective.Plugins, append(location, "plugins")) {
+			if !failed[problem.Path.String()] {
+				problems = append(problems, problem)
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkPlugins validates the plugins declared at a location, either the manifest's or a profile's. Artifacts that
+// would overwrite each other are looked for among every plugin installed alongside them, and reported on every
+// declared plugin but the first.
+func checkPlugins(manifest *lib.Manifest, declared map[string]lib.Plugin, installed map[string]lib.Plugin, prefix Location) []Problem {
+	var problems []Problem
+	add := func(location Location, format string, args ...any) {
+		problems = append(problems, Problem{Path: location, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range sortedNames(declared) {
+		plugin := declared[key]
+		location := func(segments ...string) Location {
+			return append(append(append(Location{}, prefix...), key), segments...)
+		}
+
+		if !pluginKey.MatchString(key) {
+			add(location(), "plugin key must be owner/repo")
+		}
+
+		switch {
+		case strings.TrimSpace(plugin.Tag) == "":
+			add(location(), "tag is required")
+		case strings.ContainsAny(plugin.Tag, " \t\r\n"):
+			add(location("tag"), "tag %q contains whitespace", plugin.Tag)
+		}
+
+		artifact := plugin.Artifact
+		switch {
+		case strings.TrimSpace(artifact) == "":
+			add(location(), "artifact is required")
+		case strings.ContainsAny(artifact, `/\`) || !filepath.IsLocal(artifact) || artifact == "." || artifact == "..":
+			add(location("artifact"), "artifact %q must be a file name, not a path", artifact)
+		default:
+			target := TargetOf(plugin)
+			for _, other := range sortedNames(installed) {
+				_, alsoDeclared := declared[other]
+				if other == key || (alsoDeclared && other > key) {
+					continue
+				}
+				if installed[other].Artifact == artifact && TargetOf(installed[other]) == target {
+					add(location("artifact"), "artifact %q is also installed into %s by %s", artifact, target, other)
+					break
+				}
+			}
+		}
+
+		if plugin.Hash != nil && *plugin.Hash != "" && !sha3Digest.MatchString(*plugin.Hash) {
+			add(location("hash"), "hash must be a hex SHA3-512 digest (128 characters)")
+		}
+		if constraint := strings.TrimSpace(plugin.Keycloak); constraint != "" {
+			if _, err := Satisfies("0.0.0", constraint); err != nil {
+				add(location("keycloak"), "%v", err)
+			}
+		}
+		for i, mirror := range plugin.Mirrors {
+			if strings.TrimSpace(mirror) == "" {
+				add(location("mirrors", fmt.Sprintf("[%d]", i)), "mirror is empty")
+			}
+		}
+
+		if target := plugin.Target; target != "" && target != DefaultTarget && manifest.Targets[target] == "" {
+			add(location("target"), "target %q is not defined in targets", target)
+		}
+
+		switch strings.ToLower(plugin.Archive) {
+		case "", "zip", "tar", "tar.gz", "tgz":
+		default:
+			add(location("archive"), "unsupported archive format %q (expected zip, tar or tar.gz)", plugin.Archive)
+		}
+		if plugin.Archive == "" && len(plugin.Extract) > 0 {
+			add(location("extract"), "extract only applies to archives")
+		}
+		if plugin.Archive == "" && plugin.StripComponents != 0 {
+			add(location("strip-components"), "strip-components only applies to archives")
+		}
+		if plugin.StripComponents < 0 {
+			add(location("strip-components"), "strip-components can't be negative")
+		}
+	}
+
+	return problems
+}
+
+// sortedNames returns the keys of a map, sorted.
+func sortedNames[V any](values map[string]V) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Line returns the line of a manifest on which a location is declared, or 0 if it can't be found.
+// It understands the layouts of every supported format: "key:" in JSON and YAML, "key =" in TOML, HCL and INI,
+// [table.key] headers in TOML and INI, and key "label" { blocks in HCL. When a key can't be found,
+// the line of its closest parent is returned instead.
+func Line(content []byte, location Location) int {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	found, start := 0, 0
+	for _, segment := range location {
+		if strings.HasPrefix(segment, "[") {
+			continue
+		}
+
+		quoted := regexp.QuoteMeta(segment)
+		pattern := regexp.MustCompile(`(?i)(^|[\s{,.\[])["']?` + quoted + `["']?(\s*[:={\]]|\.|\s+")`)
+
+		matched := false
+		for i := start; i < len(lines); i++ {
+			if pattern.MatchString(lines[i]) {
+				found, start, matched = i+1, i, true
+				break
+			}
+		}
+		if !matched {
+			break
+		}
+	}
+	return found
+}
+
+// Where formats the position of a location in a manifest file for messages, e.g. cloakroom.yaml:12.
+func Where(file string, location Location) string {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return file
+	}
+	if line := Line(content, location); line > 0 {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return file
+}
@@ -0,0 +1,198 @@
+package utility
+
+import (
+	"cloakroom/lib"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// valid returns a manifest that passes Check, for tests to break one value at a time.
+func valid() *lib.Manifest {
+	return &lib.Manifest{
+		Version: ManifestVersion,
+		Host:    "github.com",
+		Targets: map[string]string{"themes": "/opt/keycloak/themes"},
+		Plugins: map[string]lib.Plugin{
+			"acme/theme":  {Tag: "v1.0.0", Artifact: "theme.jar", Target: "themes"},
+			"acme/events": {Tag: "v2.1.0", Artifact: "events.jar", Keycloak: ">= 21, < 27"},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	hash := func(value string) *string { return &value }
+
+	tests := []struct {
+		name    string
+		change  func(manifest *lib.Manifest)
+		path    string
+		message string
+	}{
+		{"missing version", func(m *lib.Manifest) { m.Version = "" }, "", "version is required"},
+		{"unsupported version", func(m *lib.Manifest) { m.Version = "2.0" }, "version", "unsupported version"},
+		{"missing host", func(m *lib.Manifest) { m.Host = " " }, "", "host is required"},
+		{"invalid keycloak", func(m *lib.Manifest) { m.Keycloak = "=>21" }, "keycloak", "invalid constraint"},
+		{"empty mirror", func(m *lib.Manifest) { m.Mirrors = []string{"mirror.example.com", ""} }, "mirrors[1]", "mirror is empty"},
+		{"empty target", func(m *lib.Manifest) { m.Targets["scripts"] = "" }, "targets.scripts", "target directory is empty"},
+		{"invalid mode", func(m *lib.Manifest) { m.Files.Mode = "rw-r--r--" }, "files.mode", "not an octal mode"},
+		{"invalid key", func(m *lib.Manifest) {
+			m.Plugins["theme"] = lib.Plugin{Tag: "v1", Artifact: "other.jar"}
+		}, "plugins.theme", "owner/repo"},
+		{"missing tag", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Artifact: "theme.jar", Target: "themes"}
+		}, `plugins."acme/theme"`, "tag is required"},
+		{"tag with spaces", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1 beta", Artifact: "theme.jar", Target: "themes"}
+		}, `plugins."acme/theme".tag`, "contains whitespace"},
+		{"artifact path", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1", Artifact: "../theme.jar", Target: "themes"}
+		}, `plugins."acme/theme".artifact`, "must be a file name"},
+		{"duplicate artifact", func(m *lib.Manifest) {
+			m.Plugins["acme/other"] = lib.Plugin{Tag: "v1", Artifact: "events.jar"}
+		}, `plugins."acme/other".artifact`, "also installed into providers by acme/events"},
+		{"short hash", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1", Artifact: "theme.jar", Target: "themes", Hash: hash("abc")}
+		}, `plugins."acme/theme".hash`, "SHA3-512"},
+		{"unknown target", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1", Artifact: "theme.jar", Target: "scripts"}
+		}, `plugins."acme/theme".target`, `target "scripts" is not defined`},
+		{"unknown archive", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1", Artifact: "theme.rar", Target: "themes", Archive: "rar"}
+		}, `plugins."acme/theme".archive`, "unsupported archive format"},
+		{"extract without archive", func(m *lib.Manifest) {
+			m.Plugins["acme/theme"] = lib.Plugin{Tag: "v1", Artifact: "theme.jar", Target: "themes", Extract: []string{"*.jar"}}
+		}, `plugins."acme/theme".extract`, "only applies to archives"},
+		{"unknown profile removal", func(m *lib.Manifest) {
+			m.Profiles = map[string]lib.Profile{"prod": {Remove: []string{"acme/missing"}}}
+		}, "profiles.prod.remove[0]", "not in the manifest"},
+		{"profile plugin without artifact", func(m *lib.Manifest) {
+			m.Profiles = map[string]lib.Profile{"dev": {Plugins: map[string]lib.Plugin{"acme/debug": {Tag: "v1"}}}}
+		}, `profiles.dev.plugins."acme/debug"`, "artifact is required"},
+		{"profile artifact clash", func(m *lib.Manifest) {
+			m.Profiles = map[string]lib.Profile{"dev": {Plugins: map[string]lib.Plugin{"acme/debug": {Tag: "v1", Artifact: "events.jar"}}}}
+		}, `profiles.dev.plugins."acme/debug".artifact`, "also installed"},
+		{"profile interpolation", func(m *lib.Manifest) {
+			m.Profiles = map[string]lib.Profile{"dev": {Plugins: map[string]lib.Plugin{"acme/theme": {Tag: "${CLOAKROOM_TEST_UNSET:?set it}"}}}}
+		}, `profiles.dev.plugins."acme/theme".tag`, "set it"},
+	}
+
+	if problems := Check(valid()); len(problems) != 0 {
+		t.Fatalf("Check(valid()) = %v, want no problems", problems)
+	}
+
+	for _, test := range tests {
+		manifest := valid()
+		test.change(manifest)
+
+		problems := Check(manifest)
+		if len(problems) != 1 {
+			t.Errorf("%s: got %d problems %v, want 1", test.name, len(problems), problems)
+			continue
+		}
+		if problems[0].Path.String() != test.path || !strings.Contains(problems[0].Message, test.message) {
+			t.Errorf("%s: got %s: %s, want %s: ...%s...", test.name, problems[0].Path, problems[0].Message, test.path, test.message)
+		}
+	}
+}
+
+func TestCheckProfiles(t *testing.T) {
+	t.Setenv("CLOAKROOM_TEST_TAG", "v1.1.0")
+	hash := "${CLOAKROOM_TEST_HASH:-" + strings.Repeat("b", 128) + "}"
+
+	manifest := valid()
+	manifest.Profiles = map[string]lib.Profile{
+		// Overrides only list what they change, and are interpolated before they are checked
+		"prod": {Host: "${CLOAKROOM_TEST_HOST:-git.example.com}", Plugins: map[string]lib.Plugin{
+			"acme/theme": {Tag: "${CLOAKROOM_TEST_TAG}", Hash: &hash},
+		}},
+	}
+
+	if problems := Check(manifest); len(problems) != 0 {
+		t.Errorf("Check() = %v, want no problems", problems)
+	}
+	plugin := manifest.Profiles["prod"].Plugins["acme/theme"]
+	if plugin.Tag != "${CLOAKROOM_TEST_TAG}" || *plugin.Hash != hash || hash[0] != '$' {
+		t.Errorf("Check() changed the profile to tag %q, hash %q", plugin.Tag, *plugin.Hash)
+	}
+}
+
+func TestStructure(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cloakroom.yaml")
+	content := `version: "1.0"
+host: github.com
+wardrobe: ./providers
+colour: blue
+network:
+  timeout: 30s
+  retries: 3
+plugins:
+  acme/theme:
+    tag: v1.0.0
+    artifact: theme.jar
+    checksum: abc
+`
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Structure(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, problem := range problems {
+		got = append(got, problem.Path.String()+": "+problem.Message)
+	}
+	want := []string{
+		"colour: unknown field",
+		"network.retries: unknown field",
+		`plugins."acme/theme".checksum: unknown field`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Structure() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		location Location
+		want     int
+	}{
+		{"yaml", "version: \"1.0\"\nplugins:\n  acme/theme:\n    tag: v1\n", Location{"plugins", "acme/theme", "tag"}, 4},
+		{"yaml parent", "plugins:\n  acme/theme:\n    tag: v1\n", Location{"plugins", "acme/theme", "hash"}, 2},
+		{"json", "{\n  \"plugins\": {\n    \"acme/theme\": {\n      \"tag\": \"v1\"\n    }\n  }\n}\n", Location{"plugins", "acme/theme", "tag"}, 4},
+		{"toml table", "version = \"1.0\"\n\n[plugins.\"acme/theme\"]\ntag = \"v1\"\n", Location{"plugins", "acme/theme", "tag"}, 4},
+		{"list entry", "mirrors:\n  - a\n  - b\n", Location{"mirrors", "[1]"}, 1},
+		{"missing", "host: github.com\n", Location{"plugins"}, 0},
+	}
+
+	for _, test := range tests {
+		if got := Line([]byte(test.content), test.location); got != test.want {
+			t.Errorf("%s: Line(%s) = %d, want %d", test.name, test.location, got, test.want)
+		}
+	}
+}
+
+func TestLocationString(t *testing.T) {
+	tests := []struct {
+		location Location
+		want     string
+	}{
+		{Location{}, ""},
+		{Location{"version"}, "version"},
+		{Location{"plugins", "acme/theme", "tag"}, `plugins."acme/theme".tag`},
+		{Location{"mirrors", "[0]"}, "mirrors[0]"},
+		{Location{"files", "dir-mode"}, "files.dir-mode"},
+	}
+
+	for _, test := range tests {
+		if got := test.location.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.location, got, test.want)
+		}
+	}
+}
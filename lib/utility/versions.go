@@ -0,0 +1,196 @@
+package utility
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var numeric = regexp.MustCompile(`\d+(\.\d+)*`)
+
+// ParseVersion reads the leading numeric components of a version such as "v21.1.1" or "26.1.0.Final".
+func ParseVersion(version string) ([]int, error) {
+	match := numeric.FindString(strings.TrimPrefix(strings.TrimSpace(version), "v"))
+	if match == "" {
+		return nil, fmt.Errorf("invalid version %q", version)
+	}
+
+	parts := strings.Split(match, ".")
+	components := make([]int, len(parts))
+	for i, part := range parts {
+		components[i], _ = strconv.Atoi(part)
+	}
+	return components, nil
+}
+
+// compare orders two versions component by component, treating missing components as zero.
+func compare(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Satisfies reports whether version matches a constraint.
+//
+// A constraint is one or more alternatives separated by "||", each made of comparisons separated by commas,
+// e.g. ">= 21, < 23". Spaces around the operator are ignored.
+//   - "21", "21.1", "21.x" or "21.*" match every version with that prefix;
+//   - "=", "!=", ">", ">=", "<" and "<=" compare against a version;
+//   - "~21.1" allows patch updates (21.1.x) and "^21.1" allows minor updates (21.x). Below 1.0, "^" only allows
+//     updates that keep the first non-zero component, so "^0.2" allows 0.2.x and "^0.0.3" only 0.0.3.
+//
+// Versions may carry qualifiers, e.g. ">=21.0.0-SNAPSHOT": comparisons place pre-releases before their release,
+// and other qualifiers are ignored.
+func Satisfies(version string, constraint string) (bool, error) {
+	target, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	for _, alternative := range strings.Split(constraint, "||") {
+		var comparisons []string
+		for _, comparison := range strings.Split(alternative, ",") {
+			if comparison = strings.TrimSpace(comparison); comparison != "" {
+				comparisons = append(comparisons, comparison)
+			}
+		}
+		if len(comparisons) == 0 {
+			continue
+		}
+
+		matched := true
+		for _, comparison := range comparisons {
+			ok, err := satisfies(version, target, comparison)
+			if err != nil {
+				return false, fmt.Errorf("invalid constraint %q: %w", constraint, err)
+			}
+			matched = matched && ok
+		}
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// satisfies evaluates a single comparison such as ">=21" or "~22.0" against version, whose components are target.
+func satisfies(version string, target []int, comparison string) (bool, error) {
+	operand := strings.TrimLeft(comparison, "<>=!~^")
+	operator := comparison[:len(comparison)-len(operand)]
+	operand = strings.TrimSpace(operand)
+	if strings.ContainsAny(operand, " \t") {
+		return false, fmt.Errorf("missing comma between comparisons in %q", comparison)
+	}
+	operand = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(operand, ".*"), ".x"), ".X")
+	if operand == "*" || operand == "x" || operand == "X" {
+		return true, nil
+	}
+
+	bound, err := ParseVersion(operand)
+	if err != nil {
+		return false, err
+	}
+	order := CompareVersions(version, operand)
+
+	switch operator {
+	case "", "=", "==":
+		// Bare versions match by prefix, so "21" accepts any 21.x.y
+		return len(target) >= len(bound) && compare(target[:len(bound)], bound) == 0 || order == 0, nil
+	case "!=":
+		return order != 0, nil
+	case ">":
+		return order > 0, nil
+	case ">=":
+		return order >= 0, nil
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case "~":
+		prefix := bound[:min(len(bound), 2)]
+		return order >= 0 && compare(truncate(target, len(prefix)), prefix) == 0, nil
+	case "^":
+		// The first non-zero component is fixed, or every component if they are all zero
+		significant := len(bound)
+		for i, component := range bound {
+			if component != 0 {
+				significant = i + 1
+				break
+			}
+		}
+		return order >= 0 && compare(truncate(target, significant), bound[:significant]) == 0, nil
+	default:
+		return false, fmt.Errorf("unknown operator %q", operator)
+	}
+}
+
+// truncate returns the first n components of a version.
+func truncate(version []int, n int) []int {
+	return version[:min(len(version), n)]
+}
+
+var distribution = regexp.MustCompile(`^org\.keycloak\.keycloak-[a-z-]+-(\d+\.\d+\.\d+.*)\.jar$`)
+
+// DetectKeycloak reads the version of the Keycloak distribution installed at home,
+// from version.txt or from the org.keycloak.keycloak-*.jar libraries in lib/lib/main.
+func DetectKeycloak(home string) (string, error) {
+	if content, err := os.ReadFile(filepath.Join(home, "version.txt")); err == nil {
+		if version := numeric.FindString(string(content)); version != "" {
+			return version, nil
+		}
+	}
+
+	jars, _ := filepath.Glob(filepath.Join(home, "lib", "lib", "main", "org.keycloak.keycloak-*.jar"))
+	sort.Strings(jars)
+	for _, jar := range jars {
+		if match := distribution.FindStringSubmatch(filepath.Base(jar)); match != nil {
+			return match[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("no Keycloak distribution found in %s", home)
+}
+
+// prerelease matches the qualifiers that make a version sort before its release, e.g. "1.0.0-rc1" or "2.0-SNAPSHOT".
+var prerelease = regexp.MustCompile(`(?i)[.-](alpha|beta|rc|cr|m\d|milestone|snapshot|preview|pre)`)
+
+// CompareVersions orders two versions by their numeric components, placing pre-releases before the release
+// they lead up to. Versions without numeric components compare as equal only to themselves.
+func CompareVersions(a, b string) int {
+	x, errX := ParseVersion(a)
+	y, errY := ParseVersion(b)
+	if errX != nil || errY != nil {
+		return strings.Compare(a, b)
+	}
+
+	if order := compare(x, y); order != 0 {
+		return order
+	}
+
+	preX, preY := prerelease.MatchString(a), prerelease.MatchString(b)
+	switch {
+	case preX && !preY:
+		return -1
+	case !preX && preY:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package utility
+
+import "testing"
+
+func TestSatisfies(t *testing.T) {
+	tests := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{"21.1.1", "21", true},
+		{"22.0.0", "21", false},
+		{"21.1.1", "21.x", true},
+		{"21.1.1", "21.1.*", true},
+		{"21.1.1", "*", true},
+		{"21.1.1", "=21.1.1", true},
+		{"21.1.1", "!=21.1.1", false},
+		{"21.0.0", ">=21", true},
+		{"20.9.9", ">=21", false},
+		{"22.0.0", ">=21,<23", true},
+		{"22.0.0", ">= 21, < 23", true},
+		{"23.0.0", ">= 21, < 23", false},
+		{"22.0.0", " >=21 , <23 ", true},
+		{"20.0.0", "<21 || >=23", true},
+		{"22.0.0", "<21 || >=23", false},
+		{"24.0.0", "< 21 || >= 23", true},
+		{"21.0.0-SNAPSHOT", ">=21.0.0-SNAPSHOT", true},
+		{"21.0.0", ">=21.0.0-SNAPSHOT", true},
+		{"21.0.0-SNAPSHOT", ">=21.0.0", false},
+		{"21.1.5", "~21.1", true},
+		{"21.2.0", "~21.1", false},
+		{"21.9.0", "^21.1", true},
+		{"22.0.0", "^21.1", false},
+		{"0.2.5", "~0.2", true},
+		{"0.3.0", "~0.2", false},
+		{"0.2.5", "~0.2.3", true},
+		{"0.2.1", "~0.2.3", false},
+		{"0.2.5", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.2.1", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+		{"0.0.9", "^0.0", true},
+		{"0.1.0", "^0.0", false},
+		{"0.9.0", "^0", true},
+		{"1.0.0", "^0", false},
+	}
+
+	for _, test := range tests {
+		got, err := Satisfies(test.version, test.constraint)
+		if err != nil {
+			t.Errorf("Satisfies(%q, %q): unexpected error %v", test.version, test.constraint, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("Satisfies(%q, %q) = %v, want %v", test.version, test.constraint, got, test.want)
+		}
+	}
+}
+
+func TestSatisfiesInvalid(t *testing.T) {
+	for _, constraint := range []string{">=", ">=21 <23", "=>21", "~>21", "abc"} {
+		if _, err := Satisfies("21.0.0", constraint); err == nil {
+			t.Errorf("Satisfies(%q): expected an error", constraint)
+		}
+	}
+}
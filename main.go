@@ -5,6 +5,8 @@ package main
 
 import "cloakroom/cmd"
 
+//go:generate go run . schema --output cloakroom.schema.json
+
 func main() {
 	cmd.Execute()
 }